@@ -1,4 +1,11 @@
 // Monkey language interpreter
+//
+// Usage:
+//
+//	monkey [file]
+//
+// The program in file, or piped to stdin when no file is given, is run.  Without
+// either an interactive session is started.
 package main
 
 import (
@@ -17,6 +24,21 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s [file]\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	if len(os.Args) == 2 {
+		runFile(os.Args[1])
+		return
+	}
+
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice == 0 {
+		run("<stdin>", os.Stdin)
+		return
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		log.Fatalf("Failed to get username: %s", err)
@@ -28,3 +50,22 @@ func main() {
 
 	fmt.Println("Goodbye", user.Username)
 }
+
+// Runs the program in the file named filename.
+func runFile(filename string) {
+	file, err := os.Open(filename)
+	if err != nil {
+		log.Fatalf("Failed to open program: %s", err)
+	}
+	defer file.Close()
+
+	run(filename, file)
+}
+
+// Runs the program read from file exiting with a non-zero status on failure.
+func run(name string, file *os.File) {
+	if !repl.Run(name, file, os.Stderr) {
+		file.Close()
+		os.Exit(1)
+	}
+}
@@ -11,6 +11,8 @@ import (
 type Node interface {
 	TokenLiteral() string // Used for debugging and testing
 	String() string
+	Pos() token.Position // position of the first character of the node
+	End() token.Position // position immediately after the node
 }
 
 type Statement interface {
@@ -35,6 +37,20 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -45,6 +61,31 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// AssignStatement updates an existing variable, e.g. `x = 1` or `x += 1`.
+type AssignStatement struct {
+	Token    token.Token // the assignment operator token
+	Target   Expression
+	Operator string // = or a compound assignment operator such as +=
+	Value    Expression
+}
+
+func (as *AssignStatement) statementNode()       {}
+func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignStatement) Pos() token.Position  { return as.Target.Pos() }
+func (as *AssignStatement) End() token.Position  { return endOf(as.Value, as.Token) }
+func (as *AssignStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(as.Target.String())
+	out.WriteString(" " + as.Operator + " ")
+	if as.Value != nil {
+		out.WriteString(as.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
 type ReturnStatement struct {
 	Token       token.Token // the token.RETURN token
 	ReturnValue Expression
@@ -52,6 +93,8 @@ type ReturnStatement struct {
 
 func (r *ReturnStatement) statementNode()       {}
 func (r *ReturnStatement) TokenLiteral() string { return r.Token.Literal }
+func (r *ReturnStatement) Pos() token.Position  { return r.Token.Pos }
+func (r *ReturnStatement) End() token.Position  { return endOf(r.ReturnValue, r.Token) }
 func (r *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -74,6 +117,16 @@ type LetStatement struct {
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	if ls.Name != nil {
+		return ls.Name.End()
+	}
+	return ls.Token.End
+}
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -90,6 +143,87 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// WhileStatement repeats Body as long as Condition is truthy, e.g.
+// `while (x < 10) { x += 1 }`.
+type WhileStatement struct {
+	Token     token.Token // the token.WHILE token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position {
+	if ws.Body != nil {
+		return ws.Body.End()
+	}
+	return endOf(ws.Condition, ws.Token)
+}
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// ForStatement runs Body once for every element of Iterable with Variable bound
+// to the element, e.g. `for (x in [1, 2, 3]) { puts(x) }`.
+type ForStatement struct {
+	Token    token.Token // the token.FOR token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Position {
+	if fs.Body != nil {
+		return fs.Body.End()
+	}
+	return endOf(fs.Iterable, fs.Token)
+}
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// BreakStatement ends the innermost enclosing loop.
+type BreakStatement struct {
+	Token token.Token // the token.BREAK token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position  { return bs.Token.End }
+
+// ContinueStatement skips to the next iteration of the innermost enclosing loop.
+type ContinueStatement struct {
+	Token token.Token // the token.CONTINUE token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position  { return cs.Token.End }
+
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	Value string
@@ -98,6 +232,8 @@ type Identifier struct {
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 
 type IntegerLiteral struct {
 	Token token.Token // the token.IDENT token
@@ -107,6 +243,19 @@ type IntegerLiteral struct {
 func (i *IntegerLiteral) expressionNode()      {}
 func (i *IntegerLiteral) TokenLiteral() string { return i.Token.Literal }
 func (i *IntegerLiteral) String() string       { return i.Token.Literal }
+func (i *IntegerLiteral) Pos() token.Position  { return i.Token.Pos }
+func (i *IntegerLiteral) End() token.Position  { return i.Token.End }
+
+type FloatLiteral struct {
+	Token token.Token // the token.FLOAT token
+	Value float64
+}
+
+func (f *FloatLiteral) expressionNode()      {}
+func (f *FloatLiteral) TokenLiteral() string { return f.Token.Literal }
+func (f *FloatLiteral) String() string       { return f.Token.Literal }
+func (f *FloatLiteral) Pos() token.Position  { return f.Token.Pos }
+func (f *FloatLiteral) End() token.Position  { return f.Token.End }
 
 type ExpressionStatement struct {
 	Token      token.Token
@@ -115,6 +264,8 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position  { return endOf(es.Expression, es.Token) }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -131,6 +282,8 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position  { return endOf(pe.Right, pe.Token) }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -151,6 +304,13 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *InfixExpression) End() token.Position { return endOf(ie.Right, ie.Token) }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -171,14 +331,19 @@ type Boolean struct {
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
 
 type BlockStatement struct {
 	Token      token.Token // The { token
 	Statements []Statement
+	EndToken   token.Token // The } token
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position  { return bs.EndToken.End }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -198,6 +363,16 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	if ie.Consequence != nil {
+		return ie.Consequence.End()
+	}
+	return endOf(ie.Condition, ie.Token)
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -214,21 +389,46 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// Returns the source form of each parameter in a parameter list, including its
+// default value and the rest parameter.
+func ParameterStrings(parameters []*Identifier, defaults map[string]Expression, rest *Identifier) []string {
+	params := []string{}
+	for _, p := range parameters {
+		if value, ok := defaults[p.Value]; ok {
+			params = append(params, p.String()+" = "+value.String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if rest != nil {
+		params = append(params, "..."+rest.String())
+	}
+
+	return params
+}
+
 type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
+	Defaults   map[string]Expression // default values keyed by parameter name
+	Rest       *Identifier           // collects the remaining arguments, if any
 	Body       *BlockStatement
+	Name       string // the name the function is bound to by let, if any
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position {
+	if fl.Body != nil {
+		return fl.Body.End()
+	}
+	return fl.Token.End
+}
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
+	params := ParameterStrings(fl.Parameters, fl.Defaults, fl.Rest)
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
@@ -243,10 +443,18 @@ type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // The Identifier or FunctionLiteral
 	Arguments []Expression
+	EndToken  token.Token // The ')' token
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position {
+	if ce.Function != nil {
+		return ce.Function.Pos()
+	}
+	return ce.Token.Pos
+}
+func (ce *CallExpression) End() token.Position { return ce.EndToken.End }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -271,14 +479,43 @@ type StringLiteral struct {
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+
+type InterpolatedString struct {
+	Token    token.Token  // The first STRING_PART token
+	Parts    []Expression // The *StringLiteral text and the embedded expressions
+	EndToken token.Token  // The STRING_END token
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) End() token.Position  { return is.EndToken.End }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, p := range is.Parts {
+		if s, ok := p.(*StringLiteral); ok {
+			out.WriteString(s.Value)
+		} else {
+			out.WriteString("${" + p.String() + "}")
+		}
+	}
+
+	return out.String()
+}
 
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	EndToken token.Token // the ']' token
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position  { return al.EndToken.End }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -295,13 +532,21 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // The '[' token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The '[' token
+	Left     Expression
+	Index    Expression
+	EndToken token.Token // The ']' token
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *IndexExpression) End() token.Position { return ie.EndToken.End }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -315,12 +560,15 @@ func (ie *IndexExpression) String() string {
 }
 
 type HashLiteral struct {
-	Token token.Token // The '{' token
-	Pairs map[Expression]Expression
+	Token    token.Token // The '{' token
+	Pairs    map[Expression]Expression
+	EndToken token.Token // The '}' token
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position  { return hl.EndToken.End }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
@@ -335,3 +583,37 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// BadStatement is a placeholder for a statement that could not be parsed.  It
+// spans the tokens skipped while recovering from the error.
+type BadStatement struct {
+	Token    token.Token // the first token of the statement
+	EndToken token.Token // the last token skipped
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadStatement) String() string       { return "<bad statement>" }
+func (bs *BadStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BadStatement) End() token.Position  { return bs.EndToken.End }
+
+// BadExpression is a placeholder for an expression that could not be parsed.
+type BadExpression struct {
+	Token    token.Token // the first token of the expression
+	EndToken token.Token // the token where the error was detected
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BadExpression) String() string       { return "<bad expression>" }
+func (be *BadExpression) Pos() token.Position  { return be.Token.Pos }
+func (be *BadExpression) End() token.Position  { return be.EndToken.End }
+
+// Returns the end position of n.  When n is missing, which happens for incomplete
+// input, the end position of t is returned instead.
+func endOf(n Node, t token.Token) token.Position {
+	if n == nil {
+		return t.End
+	}
+	return n.End()
+}
@@ -0,0 +1,147 @@
+// Diagnostics reported while processing Monkey source
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/freddiehaddad/monkey.interpreter/pkg/token"
+)
+
+type Severity string
+
+// Severities
+const (
+	ERROR   = "error"
+	WARNING = "warning"
+	NOTE    = "note"
+)
+
+type Code string
+
+// Diagnostic codes
+const (
+	ILLEGAL_CHARACTER    = "E0001"
+	UNTERMINATED_STRING  = "E0002"
+	UNEXPECTED_TOKEN     = "E0003"
+	MISSING_EXPRESSION   = "E0004"
+	INVALID_INTEGER      = "E0005"
+	UNTERMINATED_COMMENT = "E0006"
+	INVALID_ESCAPE       = "E0007"
+	INVALID_UTF8         = "E0008"
+	INVALID_FLOAT        = "E0009"
+	INTEGER_OVERFLOW     = "E0010"
+	INVALID_PARAMETER    = "E0011"
+	INVALID_ASSIGNMENT   = "E0012"
+	OUTSIDE_LOOP         = "E0013"
+)
+
+// Diagnostic describes a problem found in the source.  The span of the offending
+// source runs from Pos up to, but not including, End.
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Pos      token.Position
+	End      token.Position
+	Message  string
+	Hints    []string
+}
+
+// Returns the diagnostic in the form `file:line:column: severity[code]: message`.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s[%s]: %s", d.Pos, d.Severity, d.Code, d.Message)
+}
+
+// Fprint writes d to w in a human readable form.  When source contains the text
+// the diagnostic was reported for, the offending line is printed with carets
+// underneath the span of the problem.
+func Fprint(w io.Writer, source string, d Diagnostic) {
+	line, ok := sourceLine(source, d.Pos)
+	fprint(w, line, ok, d)
+}
+
+// FprintLine writes d to w like Fprint for callers that do not keep the whole
+// source, such as those streaming it.  The line is the text of the source line at
+// d.Pos without its line terminator.
+func FprintLine(w io.Writer, line string, d Diagnostic) {
+	fprint(w, line, d.Pos.IsValid(), d)
+}
+
+// Writes d to w, printing the source line with carets underneath the span of the
+// problem if hasLine is true.
+func fprint(w io.Writer, line string, hasLine bool, d Diagnostic) {
+	fmt.Fprintf(w, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+	fmt.Fprintf(w, " --> %s\n", d.Pos)
+
+	if hasLine {
+		number := fmt.Sprintf("%d", d.Pos.Line)
+		margin := strings.Repeat(" ", len(number))
+
+		fmt.Fprintf(w, "%s |\n", margin)
+		fmt.Fprintf(w, "%s | %s\n", number, line)
+		fmt.Fprintf(w, "%s | %s\n", margin, underline(line, d.Pos, d.End))
+	}
+
+	for _, hint := range d.Hints {
+		fmt.Fprintf(w, " = hint: %s\n", hint)
+	}
+}
+
+// Fprints writes every diagnostic in diagnostics to w using Fprint.
+func Fprints(w io.Writer, source string, diagnostics []Diagnostic) {
+	for _, d := range diagnostics {
+		Fprint(w, source, d)
+	}
+}
+
+// Returns the line of source containing pos without its line terminator.  The
+// second return value is false when pos does not refer to a location in source,
+// which is always the case when the source is not available.
+func sourceLine(source string, pos token.Position) (string, bool) {
+	if !pos.IsValid() || source == "" || pos.Offset > len(source) {
+		return "", false
+	}
+
+	start := strings.LastIndex(source[:pos.Offset], "\n") + 1
+	end := strings.IndexByte(source[pos.Offset:], '\n')
+	if end < 0 {
+		end = len(source)
+	} else {
+		end += pos.Offset
+	}
+
+	return strings.TrimSuffix(source[start:end], "\r"), true
+}
+
+// Returns the carets to print underneath line for the span pos to end.  Tabs in
+// the line are preserved so the carets align regardless of the tab width.
+func underline(line string, pos token.Position, end token.Position) string {
+	var out strings.Builder
+
+	column := 1
+	for _, ch := range line {
+		if column >= pos.Column {
+			break
+		}
+		if ch == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+		column++
+	}
+
+	width := 1
+	if end.Line == pos.Line && end.Column > pos.Column {
+		width = end.Column - pos.Column
+	} else if end.Line > pos.Line {
+		width = len([]rune(line)) - pos.Column + 1
+	}
+	if width < 1 {
+		width = 1
+	}
+	out.WriteString(strings.Repeat("^", width))
+
+	return out.String()
+}
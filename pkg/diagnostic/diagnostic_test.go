@@ -0,0 +1,120 @@
+// Unit tests for the diagnostic package
+package diagnostic
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/freddiehaddad/monkey.interpreter/pkg/token"
+)
+
+func TestFprint(t *testing.T) {
+	source := "let a = 1;\n\tlet b = add(a;\n"
+
+	tests := []struct {
+		diagnostic Diagnostic
+		expected   string
+	}{
+		{
+			Diagnostic{
+				Severity: ERROR,
+				Code:     UNEXPECTED_TOKEN,
+				Pos:      token.Position{Filename: "test.mk", Offset: 24, Line: 2, Column: 14},
+				End:      token.Position{Filename: "test.mk", Offset: 25, Line: 2, Column: 15},
+				Message:  "expected next token to be ), got ; instead",
+			},
+			"error[E0003]: expected next token to be ), got ; instead\n" +
+				" --> test.mk:2:14\n" +
+				"  |\n" +
+				"2 | \tlet b = add(a;\n" +
+				"  | \t            ^\n",
+		},
+		{
+			Diagnostic{
+				Severity: ERROR,
+				Code:     ILLEGAL_CHARACTER,
+				Pos:      token.Position{Offset: 0, Line: 1, Column: 1},
+				End:      token.Position{Offset: 3, Line: 1, Column: 4},
+				Message:  "illegal",
+				Hints:    []string{"remove it"},
+			},
+			"error[E0001]: illegal\n" +
+				" --> 1:1\n" +
+				"  |\n" +
+				"1 | let a = 1;\n" +
+				"  | ^^^\n" +
+				" = hint: remove it\n",
+		},
+		{
+			Diagnostic{
+				Severity: WARNING,
+				Code:     MISSING_EXPRESSION,
+				Message:  "no source",
+			},
+			"warning[E0004]: no source\n" +
+				" --> -\n",
+		},
+	}
+
+	for i, tt := range tests {
+		var out bytes.Buffer
+		Fprint(&out, source, tt.diagnostic)
+		if out.String() != tt.expected {
+			t.Errorf("tests[%d] - output wrong.\nexpected=\n%s\ngot=\n%s", i, tt.expected, out.String())
+		}
+	}
+}
+
+func TestFprintWithoutSource(t *testing.T) {
+	d := Diagnostic{
+		Severity: ERROR,
+		Code:     ILLEGAL_CHARACTER,
+		Pos:      token.Position{Filename: "<stdin>", Offset: 0, Line: 1, Column: 1},
+		End:      token.Position{Filename: "<stdin>", Offset: 1, Line: 1, Column: 2},
+		Message:  "illegal character \"#\"",
+	}
+
+	var out bytes.Buffer
+	Fprint(&out, "", d)
+
+	expected := "error[E0001]: illegal character \"#\"\n --> <stdin>:1:1\n"
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestFprintLine(t *testing.T) {
+	d := Diagnostic{
+		Severity: ERROR,
+		Code:     UNEXPECTED_TOKEN,
+		Pos:      token.Position{Filename: "<stdin>", Offset: 1024, Line: 40, Column: 14},
+		End:      token.Position{Filename: "<stdin>", Offset: 1025, Line: 40, Column: 15},
+		Message:  "expected next token to be ), got ; instead",
+	}
+
+	var out bytes.Buffer
+	FprintLine(&out, "let b = add(a;", d)
+
+	expected := "error[E0003]: expected next token to be ), got ; instead\n" +
+		" --> <stdin>:40:14\n" +
+		"   |\n" +
+		"40 | let b = add(a;\n" +
+		"   |              ^\n"
+	if out.String() != expected {
+		t.Errorf("output wrong.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestString(t *testing.T) {
+	d := Diagnostic{
+		Severity: ERROR,
+		Code:     UNEXPECTED_TOKEN,
+		Pos:      token.Position{Filename: "test.mk", Offset: 4, Line: 1, Column: 5},
+		Message:  "expected next token to be IDENT, got = instead",
+	}
+
+	expected := "test.mk:1:5: error[E0003]: expected next token to be IDENT, got = instead"
+	if d.String() != expected {
+		t.Errorf("d.String() wrong. expected=%q, got=%q", expected, d.String())
+	}
+}
@@ -3,11 +3,14 @@ package evaluator
 
 import (
 	"fmt"
+	"unicode/utf8"
 
 	"github.com/freddiehaddad/monkey.interpreter/pkg/object"
 )
 
 var builtins = map[string]*object.Builtin{
+	// len returns the number of elements in an array or the number of characters
+	// (Unicode code points, not bytes) in a string.
 	"len": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
@@ -18,7 +21,7 @@ var builtins = map[string]*object.Builtin{
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
@@ -82,6 +85,9 @@ var builtins = map[string]*object.Builtin{
 			return NULL
 		},
 	},
+	// push returns a copy of the array with the element appended, leaving the
+	// original unchanged.  To grow an array in place, which does not copy it,
+	// assign to the index one past its end: `arr[len(arr)] = element`.
 	"push": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 2 {
@@ -2,38 +2,250 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/freddiehaddad/monkey.interpreter/pkg/ast"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/object"
+	"github.com/freddiehaddad/monkey.interpreter/pkg/token"
 )
 
 var (
 	NULL  = &object.Null{}
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+)
+
+// The maximum number of nested function calls used when Config.MaxCallDepth is
+// zero.  It keeps runaway recursion well clear of the Go stack limit.
+const DEFAULT_MAX_CALL_DEPTH = 10000
+
+// The number of entries shown at each end of a call chain that is too long to be
+// reported in full.
+const CALL_CHAIN_EDGE = 5
+
+// Approximate sizes in bytes used to account for allocations against
+// Config.MaxAllocatedBytes.
+const (
+	ELEMENT_SIZE   = 16 // an element of an array
+	HASH_PAIR_SIZE = 64 // a key-value pair of a hash including its hash key
 )
 
+// Causes of the *object.Error returned when a limit of Config is exceeded.  Use
+// errors.Is to check for them.
+var (
+	ErrStepLimitExceeded   = errors.New("step limit exceeded")
+	ErrMemoryLimitExceeded = errors.New("memory limit exceeded")
+)
+
+// Config controls optional behavior of an Evaluator.  The zero value is the
+// default configuration.
+type Config struct {
+	// CheckedArithmetic makes integer overflow in +, -, *, /, ** and negation an
+	// error instead of silently wrapping around.
+	CheckedArithmetic bool
+
+	// MaxCallDepth limits the number of nested calls of user-defined functions.
+	// Exceeding it is an error.  Zero selects DEFAULT_MAX_CALL_DEPTH and a
+	// negative value removes the limit.
+	MaxCallDepth int
+
+	// MaxSteps limits the number of nodes evaluated.  Exceeding it stops the
+	// evaluation with an error caused by ErrStepLimitExceeded.  Zero means no
+	// limit.
+	MaxSteps int64
+
+	// MaxAllocatedBytes limits the approximate number of bytes allocated for the
+	// contents of strings, arrays and hashes.  Memory reclaimed by the garbage
+	// collector is not subtracted.  Exceeding it stops the evaluation with an
+	// error caused by ErrMemoryLimitExceeded.  Zero means no limit.
+	MaxAllocatedBytes int64
+}
+
+// Evaluator evaluates Monkey programs according to its Config.  An Evaluator
+// keeps track of the functions being called and must not be used by multiple
+// goroutines at once.  The steps and allocations limited by Config are counted
+// over the lifetime of the Evaluator.
+type Evaluator struct {
+	config    Config
+	calls     []frame         // functions being called, outermost first
+	ctx       context.Context // nil unless evaluating with EvalContext
+	steps     int64           // number of nodes evaluated
+	allocated int64           // approximate number of bytes allocated
+}
+
+// New creates an Evaluator using config.
+func New(config Config) *Evaluator {
+	if config.MaxCallDepth == 0 {
+		config.MaxCallDepth = DEFAULT_MAX_CALL_DEPTH
+	}
+	return &Evaluator{config: config}
+}
+
+// Eval evaluates node in env using the default configuration.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(Config{}).Eval(node, env)
+}
+
+// frame is a call of a user-defined function that is being evaluated.
+type frame struct {
+	function *object.Function
+	pos      token.Position // position of the call
+}
+
+// EvalContext evaluates node in env using the default configuration until ctx is
+// done.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	return New(Config{}).EvalContext(ctx, node, env)
+}
+
+// EvalContext evaluates node in env like Eval, but stops once ctx is done.  The
+// context is checked at every function call and loop iteration.  Evaluation that
+// is stopped returns an *object.Error whose Cause is ctx.Err().
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	outer := e.ctx
+	e.ctx = ctx
+	defer func() { e.ctx = outer }()
+
+	return e.Eval(node, env)
+}
+
+// Returns an error if the context of EvalContext is done, nil otherwise.
+func (e *Evaluator) interrupted() *object.Error {
+	if e.ctx == nil {
+		return nil
+	}
+
+	select {
+	case <-e.ctx.Done():
+		return stoppedError(e.ctx.Err())
+	default:
+		return nil
+	}
+}
+
+// Records the allocation of size bytes.  Returns an error if the total exceeds
+// Config.MaxAllocatedBytes, nil otherwise.
+func (e *Evaluator) allocate(size int) *object.Error {
+	if e.config.MaxAllocatedBytes <= 0 {
+		return nil
+	}
+
+	e.allocated += int64(size)
+	if e.allocated > e.config.MaxAllocatedBytes {
+		return stoppedError(fmt.Errorf("%w (%d bytes)", ErrMemoryLimitExceeded, e.config.MaxAllocatedBytes))
+	}
+	return nil
+}
+
+// Returns the approximate number of bytes used by the contents of a string,
+// array or hash.  The elements of an array or hash are not included.
+func sizeOf(obj object.Object) int {
+	switch obj := obj.(type) {
+	case *object.String:
+		return len(obj.Value)
+	case *object.Array:
+		return ELEMENT_SIZE * len(obj.Elements)
+	case *object.Hash:
+		return HASH_PAIR_SIZE * len(obj.Pairs)
+	default:
+		return 0
+	}
+}
+
+// Returns the error for evaluation stopped because of cause.
+func stoppedError(cause error) *object.Error {
+	err := newError("evaluation stopped: %s", cause)
+	err.Cause = cause
+	return err
+}
+
+// Eval evaluates node in env.  Problems in the program, such as division by zero,
+// are returned as *object.Error values.  The error records the span of the
+// innermost node that failed and the functions being called at the time.
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	result := e.eval(node, env)
+	if err, ok := result.(*object.Error); ok {
+		e.locate(err, node)
+	}
+	return result
+}
+
+// Sets the position of err to the span of node and records the functions being
+// called, unless the position of err is already known.  Returns err.
+func (e *Evaluator) locate(err *object.Error, node ast.Node) *object.Error {
+	if err.Pos.IsValid() || node == nil {
+		return err
+	}
+
+	err.Pos, err.End = node.Pos(), node.End()
+	err.Stack = make([]object.Frame, len(e.calls))
+	for i, call := range e.calls {
+		name := call.function.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		err.Stack[i] = object.Frame{Function: name, Pos: call.pos}
+	}
+
+	return err
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	if e.config.MaxSteps > 0 {
+		if e.steps++; e.steps > e.config.MaxSteps {
+			return stoppedError(fmt.Errorf("%w (%d steps)", ErrStepLimitExceeded, e.config.MaxSteps))
+		}
+	}
+
 	switch node := node.(type) {
 
 	// Statements
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		if call, ok := node.ReturnValue.(*ast.CallExpression); ok && len(e.calls) > 0 {
+			val := e.evalTailCall(call, env)
+			if isError(val) {
+				return val
+			}
+			return &object.ReturnValue{Value: val}
+		}
+
+		val := e.Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
 	// Expressions
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
@@ -43,74 +255,88 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.InterpolatedString:
+		return e.evalInterpolatedString(node, env)
+
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := e.Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
 
+	case *ast.AssignStatement:
+		return e.evalAssignStatement(node, env)
+
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return e.evalPrefixExpression(node.Operator, right)
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, env)
+		}
+
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 
-		return evalInfixExpression(node.Operator, left, right)
+		return e.evalInfixExpression(node.Operator, left, right)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: node.Name, Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Env: env, Body: body}
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
+		call := e.evalTailCall(node, env)
+		if isError(call) {
+			return call
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-		return applyFunction(function, args)
+		return e.applyFunction(call.(*tailCall))
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := e.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		array := &object.Array{Elements: elements}
+		if err := e.allocate(sizeOf(array)); err != nil {
+			return err
+		}
+		return array
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
+		index := e.Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 
 		return evalIndexExpression(left, index)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
+	case *ast.BadStatement, *ast.BadExpression:
+		return newError("invalid syntax at %s", node.Pos())
 	}
 	return nil
 }
@@ -140,28 +366,239 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	switch fn := fn.(type) {
+// tailCall is a call in tail position of a function body, i.e. the value of a
+// `return` statement or the final expression of the body.  It is passed up to
+// applyFunction instead of being applied, which then calls the function in place
+// of the one returning it, so that tail recursion runs in constant stack space.
+type tailCall struct {
+	function object.Object
+	args     []object.Object
+	node     *ast.CallExpression
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// Evaluates the function and arguments of a call without applying it.  Returns a
+// *tailCall or an error.
+func (e *Evaluator) evalTailCall(node *ast.CallExpression, env *object.Environment) object.Object {
+	function := e.Eval(node.Function, env)
+	if isError(function) {
+		return function
+	}
+	args := e.evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	return &tailCall{function: function, args: args, node: node}
+}
+
+// Applies call.  A call in tail position of a user-defined function replaces it,
+// so it neither adds to the call depth nor nests another call in Go.  Errors of a
+// call are located at the call, which for a tail call differs from the call
+// applyFunction started with.
+func (e *Evaluator) applyFunction(call *tailCall) object.Object {
+	depth := len(e.calls)
+	defer func() { e.calls = e.calls[:depth] }()
+
+	for {
+		if err := e.interrupted(); err != nil {
+			return e.locate(err, call.node)
+		}
+
+		switch function := call.function.(type) {
 
-	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
-	case *object.Builtin:
-		return fn.Fn(args...)
+		case *object.Function:
+			args := call.args
+			required := len(function.Parameters) - len(function.Defaults)
+			if len(args) < required || len(args) > len(function.Parameters) && function.Rest == nil {
+				err := newError("wrong number of arguments to %s. got=%d, want=%s", functionName(function), len(args), arity(function))
+				return e.locate(err, call.node)
+			}
+			// The frame is entered before the default values are evaluated, as
+			// they may call the function again.  A tail call takes over the frame
+			// of the function making it, which keeps the position of the call
+			// applyFunction started with.
+			var caller *object.Function
+			if len(e.calls) > depth {
+				caller = e.calls[depth].function
+				e.calls[depth].function = function
+			} else if e.config.MaxCallDepth > 0 && depth >= e.config.MaxCallDepth {
+				err := newError("maximum recursion depth exceeded (%d nested calls): %s", depth, e.callChain(function))
+				return e.locate(err, call.node)
+			} else {
+				e.calls = append(e.calls, frame{function: function, pos: call.node.Pos()})
+			}
+
+			// An error binding the arguments belongs to the caller.
+			extendedEnv, err := e.extendFunctionEnv(function, args)
+			if err != nil {
+				if caller != nil {
+					e.calls[depth].function = caller
+				} else {
+					e.calls = e.calls[:depth]
+				}
+				return e.locate(err, call.node)
+			}
+
+			evaluated := unwrapReturnValue(e.evalFunctionBody(function.Body, extendedEnv))
+			next, ok := evaluated.(*tailCall)
+			if !ok {
+				// A body ending in a statement without a value, such as an
+				// assignment or a loop, returns null.
+				if evaluated == nil {
+					return NULL
+				}
+				return evaluated
+			}
+			call = next
+		case *object.Builtin:
+			result := function.Fn(call.args...)
+			if err, ok := result.(*object.Error); ok {
+				return e.locate(err, call.node)
+			}
+			// Arrays returned by builtins are counted as new, like the copy made
+			// by push, even though first or last may return an existing one.
+			if _, ok := result.(*object.Array); ok {
+				if err := e.allocate(sizeOf(result)); err != nil {
+					return e.locate(err, call.node)
+				}
+			}
+			return result
+		default:
+			return e.locate(newError("not a function: %s", function.Type()), call.node)
+		}
+	}
+}
+
+// Evaluates a block in tail position of a function body.  Like evalBlockStatement,
+// except that a call that is the value of the block is returned as a *tailCall.
+func (e *Evaluator) evalFunctionBody(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+
+	for i, statement := range block.Statements {
+		if stmt, ok := statement.(*ast.ExpressionStatement); ok && i == len(block.Statements)-1 {
+			return e.evalTailExpression(stmt.Expression, env)
+		}
+
+		result = e.Eval(statement, env)
+
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+// Evaluates an expression in tail position of a function body.  A call, including
+// one that is the value of a branch of an `if`, is returned as a *tailCall.
+func (e *Evaluator) evalTailExpression(node ast.Expression, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		return e.evalTailCall(node, env)
+	case *ast.IfExpression:
+		branch, err := e.selectBranch(node, env)
+		if err != nil {
+			return err
+		}
+		if branch == nil {
+			return NULL
+		}
+		return e.evalFunctionBody(branch, env)
 	default:
-		return newError("not a function: %s", fn.Type())
+		return e.Eval(node, env)
+	}
+}
+
+// Returns the name of fn for use in error messages.
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "anonymous function"
 	}
+	return "`" + fn.Name + "`"
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// Returns the functions being called followed by next, outermost first, for use
+// in error messages.  Consecutive calls of the same function are combined and
+// only the ends of a long chain are included.
+func (e *Evaluator) callChain(next *object.Function) string {
+	calls := []*object.Function{}
+	for _, call := range e.calls {
+		calls = append(calls, call.function)
+	}
+	calls = append(calls, next)
+
+	chain := []string{}
+	for i := 0; i < len(calls); {
+		j := i + 1
+		for j < len(calls) && calls[j] == calls[i] {
+			j++
+		}
+
+		entry := functionName(calls[i])
+		if j-i > 1 {
+			entry += fmt.Sprintf(" (%d times)", j-i)
+		}
+		chain = append(chain, entry)
+		i = j
+	}
+
+	if len(chain) > 2*CALL_CHAIN_EDGE {
+		omitted := fmt.Sprintf("... %d more ...", len(chain)-2*CALL_CHAIN_EDGE)
+		chain = append(append(chain[:CALL_CHAIN_EDGE:CALL_CHAIN_EDGE], omitted), chain[len(chain)-CALL_CHAIN_EDGE:]...)
+	}
+
+	return strings.Join(chain, " -> ")
+}
+
+// Returns the number of arguments fn accepts for use in error messages.
+func arity(fn *object.Function) string {
+	required := len(fn.Parameters) - len(fn.Defaults)
+	switch {
+	case fn.Rest != nil:
+		return fmt.Sprintf("at least %d", required)
+	case required < len(fn.Parameters):
+		return fmt.Sprintf("%d to %d", required, len(fn.Parameters))
+	default:
+		return fmt.Sprintf("%d", required)
+	}
+}
+
+// Returns a new environment enclosed by the one fn was defined in with the
+// parameters of fn bound to args.  Parameters without an argument are bound to
+// their default value, evaluated in the environment fn was defined in, and the
+// rest parameter is bound to an array of the remaining arguments.  The number of
+// arguments must have been checked.  Returns an error if a default value fails to
+// evaluate.
+func (e *Evaluator) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+
+		value := e.Eval(fn.Defaults[param.Value], fn.Env)
+		if err, ok := value.(*object.Error); ok {
+			return nil, err
+		}
+		env.Set(param.Value, value)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
 	}
 
-	return env
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -172,11 +609,11 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -189,15 +626,15 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
 				return result
 			}
 		}
@@ -206,37 +643,266 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+// Evaluates a `while` loop.  The loop ends when the condition is falsy or on
+// `break`.  A `return` or an error inside the body ends the loop and is passed on.
+func (e *Evaluator) evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		if err := e.interrupted(); err != nil {
+			return err
+		}
+
+		condition := e.Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		if result, done := loopResult(e.Eval(node.Body, env)); done {
+			return result
+		}
+	}
+}
+
+// Evaluates a `for-in` loop over the elements of an array, the characters of a
+// string or the keys of a hash.  Hash keys are visited booleans first, then
+// integers and then strings, each in ascending order.  Elements appended to an array by the body are not
+// visited.  The loop variable is bound in a new scope enclosed by
+// env for every iteration, so closures created in the body capture the element
+// of their own iteration.
+func (e *Evaluator) evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := e.Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	var elements []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		elements = iterable.Elements
+	case *object.String:
+		// The characters are created one at a time as they are visited, since
+		// they are many times the size of the string.
+		for rest := iterable.Value; rest != ""; {
+			r, size := utf8.DecodeRuneInString(rest)
+			rest = rest[size:]
+			if result, done := e.evalForBody(node, &object.String{Value: string(r)}, env); done {
+				return result
+			}
+		}
+		return nil
+	case *object.Hash:
+		for _, pair := range iterable.Pairs {
+			elements = append(elements, pair.Key)
+		}
+		sort.Slice(elements, func(i, j int) bool {
+			return lessHashKey(elements[i], elements[j])
+		})
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	for _, element := range elements {
+		if result, done := e.evalForBody(node, element, env); done {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// Evaluates the body of a `for-in` loop for one element.  Returns true if the
+// loop must end along with the value the loop statement evaluates to.
+func (e *Evaluator) evalForBody(node *ast.ForStatement, element object.Object, env *object.Environment) (object.Object, bool) {
+	if err := e.interrupted(); err != nil {
+		return err, true
+	}
+
+	loopEnv := object.NewEnclosedEnvironment(env)
+	loopEnv.Set(node.Variable.Value, element)
+
+	return loopResult(e.Eval(node.Body, loopEnv))
+}
+
+// Reports whether the hash key a is visited before b by a `for-in` loop.
+func lessHashKey(a, b object.Object) bool {
+	switch a := a.(type) {
+	case *object.Boolean:
+		if b, ok := b.(*object.Boolean); ok {
+			return !a.Value && b.Value
+		}
+		return true
+	case *object.Integer:
+		switch b := b.(type) {
+		case *object.Boolean:
+			return false
+		case *object.Integer:
+			return a.Value < b.Value
+		}
+		return true
+	case *object.String:
+		if b, ok := b.(*object.String); ok {
+			return a.Value < b.Value
+		}
+		return false
+	}
+	return false
+}
+
+// Interprets the result of evaluating the body of a loop.  Returns true if the
+// loop must end along with the value the loop statement evaluates to.
+func loopResult(result object.Object) (object.Object, bool) {
+	if result == nil {
+		return nil, false
+	}
+
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return nil, true
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return result, true
+	}
+
+	return nil, false
+}
+
+// Evaluates an assignment to a variable or to an element of an array or hash.  A
+// variable is updated in the scope it was defined in and assigning to an undefined
+// variable is an error.  A compound assignment such as `x += 1` applies the binary
+// operator to the current value first.
+func (e *Evaluator) evalAssignStatement(node *ast.AssignStatement, env *object.Environment) object.Object {
+	var ident *ast.Identifier
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		ident = target
+	case *ast.IndexExpression:
+		return e.evalIndexAssignment(node, target, env)
+	default:
+		// The parser reports an invalid target and leaves the value unparsed.
+		return newError("cannot assign to %s", node.Target)
+	}
+
+	val := e.Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if node.Operator != "=" {
+		current, ok := env.Get(ident.Value)
+		if !ok {
+			return newError("cannot assign to undefined variable: %s", ident.Value)
+		}
+		if val = e.evalCompoundAssignment(node.Operator, current, val); isError(val) {
+			return val
+		}
+	}
+
+	if _, ok := env.Assign(ident.Value, val); !ok {
+		return newError("cannot assign to undefined variable: %s", ident.Value)
+	}
+
+	return nil
+}
+
+// Evaluates an assignment to an element of an array or a hash, which are updated
+// in place.  Assigning to the index one past the end of an array appends to it.
+// For a compound assignment the element must already exist.
+func (e *Evaluator) evalIndexAssignment(node *ast.AssignStatement, target *ast.IndexExpression, env *object.Environment) object.Object {
+	left := e.Eval(target.Left, env)
+	if isError(left) {
+		return left
+	}
+	index := e.Eval(target.Index, env)
+	if isError(index) {
+		return index
+	}
+	val := e.Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if node.Operator != "=" {
+		current := evalExistingIndexExpression(left, index)
+		if isError(current) {
+			return current
+		}
+		if val = e.evalCompoundAssignment(node.Operator, current, val); isError(val) {
+			return val
+		}
+	}
+
+	size := sizeOf(left)
+	if result := assignIndex(left, index, val); result != nil {
+		return result
+	}
+	if err := e.allocate(sizeOf(left) - size); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Returns the result of applying the binary operator of the compound assignment
+// operator, e.g. + for +=, to current and val.
+func (e *Evaluator) evalCompoundAssignment(operator string, current object.Object, val object.Object) object.Object {
+	return e.evalInfixExpression(strings.TrimSuffix(operator, "="), current, val)
+}
+
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	branch, err := e.selectBranch(ie, env)
+	if err != nil {
+		return err
+	}
+
+	if branch == nil {
+		return NULL
+	}
+	return e.Eval(branch, env)
+}
+
+// Evaluates the condition of ie and returns the block to evaluate, which is nil
+// when the condition is falsy and there is no alternative.  Returns an error if
+// the condition fails to evaluate.
+func (e *Evaluator) selectBranch(ie *ast.IfExpression, env *object.Environment) (*ast.BlockStatement, object.Object) {
+	condition := e.Eval(ie.Condition, env)
 	if isError(condition) {
-		return condition
+		return nil, condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
-	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
-	} else {
-		return NULL
+		return ie.Consequence, nil
 	}
+	return ie.Alternative, nil
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func (e *Evaluator) evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return e.evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalTildePrefixOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
-func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+// Evaluates a binary operator.  Numbers and strings support ordering as well as
+// equality.  Other values, including booleans and null, only support == and !=,
+// which compare identity.  Values of different types are never equal.
+func (e *Evaluator) evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+		return e.evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		if operator == "+" {
+			if err := e.allocate(sizeOf(left) + sizeOf(right)); err != nil {
+				return err
+			}
+		}
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
@@ -249,6 +915,30 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	}
 }
 
+// Evaluates && and || with short-circuit semantics: the right operand is only
+// evaluated if the left one does not determine the result.  The result is the
+// truthiness of the last operand evaluated.
+func (e *Evaluator) evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := e.Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := e.Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
 func evalBangOperatorExpression(right object.Object) object.Object {
 	switch right {
 	case TRUE:
@@ -262,23 +952,66 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+func (e *Evaluator) evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	lValue := left.(*object.Integer).Value
 	rValue := right.(*object.Integer).Value
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: lValue + rValue}
+		sum := lValue + rValue
+		if e.config.CheckedArithmetic && (sum > lValue) != (rValue > 0) {
+			return newError("integer overflow: %d + %d", lValue, rValue)
+		}
+		return &object.Integer{Value: sum}
 	case "-":
-		return &object.Integer{Value: lValue - rValue}
+		difference := lValue - rValue
+		if e.config.CheckedArithmetic && (difference < lValue) != (rValue > 0) {
+			return newError("integer overflow: %d - %d", lValue, rValue)
+		}
+		return &object.Integer{Value: difference}
 	case "*":
-		return &object.Integer{Value: lValue * rValue}
+		product := lValue * rValue
+		if e.config.CheckedArithmetic && multiplicationOverflows(lValue, rValue, product) {
+			return newError("integer overflow: %d * %d", lValue, rValue)
+		}
+		return &object.Integer{Value: product}
 	case "/":
+		if rValue == 0 {
+			return newError("division by zero: %d / 0", lValue)
+		}
+		if e.config.CheckedArithmetic && lValue == math.MinInt64 && rValue == -1 {
+			return newError("integer overflow: %d / %d", lValue, rValue)
+		}
 		return &object.Integer{Value: lValue / rValue}
+	case "%":
+		if rValue == 0 {
+			return newError("division by zero: %d %% 0", lValue)
+		}
+		return &object.Integer{Value: lValue % rValue}
+	case "**":
+		return e.evalIntegerPower(lValue, rValue)
+	case "&":
+		return &object.Integer{Value: lValue & rValue}
+	case "|":
+		return &object.Integer{Value: lValue | rValue}
+	case "^":
+		return &object.Integer{Value: lValue ^ rValue}
+	case "<<", ">>":
+		if rValue < 0 {
+			return newError("negative shift count: %d", rValue)
+		}
+		if operator == "<<" {
+			return &object.Integer{Value: lValue << uint64(rValue)}
+		}
+		return &object.Integer{Value: lValue >> uint64(rValue)}
 	case "<":
 		return nativeBoolToBooleanObject(lValue < rValue)
 	case ">":
 		return nativeBoolToBooleanObject(lValue > rValue)
+	case "<=":
+		return nativeBoolToBooleanObject(lValue <= rValue)
+	case ">=":
+		return nativeBoolToBooleanObject(lValue >= rValue)
 	case "==":
 		return nativeBoolToBooleanObject(lValue == rValue)
 	case "!=":
@@ -289,6 +1022,96 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 
 }
 
+// Evaluates an arithmetic or comparison operator where at least one operand is a
+// float.  Integer operands are promoted to floats, so the result of arithmetic is
+// always a float.  Division by zero follows IEEE 754 and results in an infinity
+// or NaN rather than an error.
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	lValue := toFloat(left)
+	rValue := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: lValue + rValue}
+	case "-":
+		return &object.Float{Value: lValue - rValue}
+	case "*":
+		return &object.Float{Value: lValue * rValue}
+	case "/":
+		return &object.Float{Value: lValue / rValue}
+	case "%":
+		return &object.Float{Value: math.Mod(lValue, rValue)}
+	case "**":
+		return &object.Float{Value: math.Pow(lValue, rValue)}
+	case "<":
+		return nativeBoolToBooleanObject(lValue < rValue)
+	case ">":
+		return nativeBoolToBooleanObject(lValue > rValue)
+	case "<=":
+		return nativeBoolToBooleanObject(lValue <= rValue)
+	case ">=":
+		return nativeBoolToBooleanObject(lValue >= rValue)
+	case "==":
+		return nativeBoolToBooleanObject(lValue == rValue)
+	case "!=":
+		return nativeBoolToBooleanObject(lValue != rValue)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// Returns base raised to the power exp.  The result is an integer, which wraps
+// around on overflow unless arithmetic is checked, or a float if exp is negative.
+func (e *Evaluator) evalIntegerPower(base int64, exp int64) object.Object {
+	if exp < 0 {
+		return &object.Float{Value: math.Pow(float64(base), float64(exp))}
+	}
+
+	lValue, rValue := base, exp
+	overflow := false
+	result := int64(1)
+	for ; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			product := result * base
+			overflow = overflow || multiplicationOverflows(result, base, product)
+			result = product
+		}
+		if exp > 1 {
+			square := base * base
+			overflow = overflow || multiplicationOverflows(base, base, square)
+			base = square
+		}
+	}
+
+	if e.config.CheckedArithmetic && overflow {
+		return newError("integer overflow: %d ** %d", lValue, rValue)
+	}
+	return &object.Integer{Value: result}
+}
+
+// Checks if the product of a and b, which wrapped around to product, overflowed.
+func multiplicationOverflows(a int64, b int64, product int64) bool {
+	if a == 0 || b == 0 {
+		return false
+	}
+	return product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64)
+}
+
+// Checks if obj is an integer or a float.
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// Returns the value of the integer or float obj as a float.
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+// Evaluates a binary operator on two strings.  Strings are ordered
+// lexicographically by Unicode code point.
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	lValue := left.(*object.String).Value
 	rValue := right.(*object.String).Value
@@ -296,8 +1119,18 @@ func evalStringInfixExpression(operator string, left object.Object, right object
 	switch operator {
 	case "+":
 		return &object.String{Value: lValue + rValue}
+	case "<":
+		return nativeBoolToBooleanObject(lValue < rValue)
+	case ">":
+		return nativeBoolToBooleanObject(lValue > rValue)
+	case "<=":
+		return nativeBoolToBooleanObject(lValue <= rValue)
+	case ">=":
+		return nativeBoolToBooleanObject(lValue >= rValue)
 	case "==":
 		return nativeBoolToBooleanObject(lValue == rValue)
+	case "!=":
+		return nativeBoolToBooleanObject(lValue != rValue)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -326,20 +1159,34 @@ func evalMinusOperatorExpression(left object.Object, right object.Object) object
 	return &object.Integer{Value: lValue - rValue}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+func (e *Evaluator) evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if e.config.CheckedArithmetic && right.Value == math.MinInt64 {
+			return newError("integer overflow: -(%d)", right.Value)
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
+}
+
+func evalTildePrefixOperatorExpression(right object.Object) object.Object {
+	if right.Type() != object.INTEGER_OBJ {
+		return newError("unknown operator: ~%s", right.Type())
+	}
 
 	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	return &object.Integer{Value: ^value}
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -369,6 +1216,26 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: " + node.Value)
 }
 
+func (e *Evaluator) evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out bytes.Buffer
+
+	for _, part := range node.Parts {
+		value := e.Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		if value == nil {
+			value = NULL
+		}
+		out.WriteString(value.Inspect())
+	}
+
+	if err := e.allocate(out.Len()); err != nil {
+		return err
+	}
+	return &object.String{Value: out.String()}
+}
+
 func evalIndexExpression(left object.Object, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
@@ -380,11 +1247,11 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 	}
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+		key := e.Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
@@ -394,7 +1261,7 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := e.Eval(valueNode, env)
 		if isError(value) {
 			return value
 		}
@@ -403,7 +1270,62 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 		pairs[hashed] = object.HashPair{Key: key, Value: value}
 	}
 
-	return &object.Hash{Pairs: pairs}
+	hash := &object.Hash{Pairs: pairs}
+	if err := e.allocate(sizeOf(hash)); err != nil {
+		return err
+	}
+	return hash
+}
+
+// Returns the element of an array or hash at index like evalIndexExpression, but
+// reports an error instead of returning null when the element does not exist.
+func evalExistingIndexExpression(left object.Object, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		if idx, ok := index.(*object.Integer); ok && (idx.Value < 0 || idx.Value >= int64(len(left.Elements))) {
+			return newError("index out of range: %d with length %d", idx.Value, len(left.Elements))
+		}
+	case *object.Hash:
+		if key, ok := index.(object.Hashable); ok {
+			if _, ok := left.Pairs[key.HashKey()]; !ok {
+				return newError("key not found: %s", index.Inspect())
+			}
+		}
+	}
+
+	return evalIndexExpression(left, index)
+}
+
+// Stores val in the array or hash at index.  An array index must be within the
+// array or one past its end, which appends val.  Returns nil on success.
+func assignIndex(container object.Object, index object.Object, val object.Object) object.Object {
+	switch container := container.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+
+		length := int64(len(container.Elements))
+		switch {
+		case idx.Value >= 0 && idx.Value < length:
+			container.Elements[idx.Value] = val
+		case idx.Value == length:
+			container.Elements = append(container.Elements, val)
+		default:
+			return newError("index out of range: %d with length %d", idx.Value, length)
+		}
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		container.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+	default:
+		return newError("index assignment not supported: %s", container.Type())
+	}
+
+	return nil
 }
 
 func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
@@ -2,11 +2,15 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/freddiehaddad/monkey.interpreter/pkg/lexer"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/object"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/parser"
+	"github.com/freddiehaddad/monkey.interpreter/pkg/token"
 )
 
 func TestHashIndexExpressions(t *testing.T) {
@@ -189,6 +193,33 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionsWithoutValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn() { }()", nil},
+		{"let count = 0; let inc = fn() { count += 1 }; inc()", nil},
+		{"let count = 0; let inc = fn() { count += 1 }; let a = [0]; a[0] = inc(); a[0]", nil},
+		{"let f = fn() { let x = 1 }; [f()][0]", nil},
+		{"let count = 0; let inc = fn() { count += 1 }; inc() + 1", "type mismatch: NULL + INTEGER"},
+		{"let count = 0; let inc = fn() { count += 1 }; let x = 0; x += inc()", "type mismatch: INTEGER + NULL"},
+		{"let count = 0; let inc = fn() { count += 1 }; for (v in inc()) { }", "cannot iterate over NULL"},
+		{"fn() { while (false) { } }() + 1", "type mismatch: NULL + INTEGER"},
+		{"fn() { for (x in []) { } }() + 1", "type mismatch: NULL + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
@@ -213,6 +244,135 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = fn(a = 1, b = 2) { a * 10 + b }; f()", 12},
+		{"let x = 5; let f = fn(a = x * 2) { a }; f()", 10},
+		{"let f = fn(...rest) { len(rest) }; f()", 0},
+		{"let f = fn(...rest) { len(rest) }; f(1, 2, 3)", 3},
+		{"let f = fn(a, ...rest) { a + rest[0] + rest[1] }; f(1, 2, 3)", 6},
+		{"let f = fn(a, b = 2, ...rest) { a + b + len(rest) }; f(1)", 3},
+		{"let f = fn(a, b = 2, ...rest) { a + b + len(rest) }; f(1, 5, 0, 0)", 8},
+		{"let counter = fn() { let n = 0; fn(step = n + 1) { step } }; counter()()", 1},
+		{"let f = fn(s = \"hi\") { s }; f()", "hi"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringLiteralObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x += 2; x", 3},
+		{"let x = 10; x -= 2; x *= 3; x /= 4; x", 6},
+		{"let x = 7; x %= 4; x **= 3; x", 27},
+		{"let x = 6; x &= 3; x |= 8; x ^= 1; x <<= 2; x >>= 1; x", 22},
+		{"let s = \"a\"; s += \"b\"; s", "ab"},
+		{"let x = 1; let f = fn() { x = 5 }; f(); x", 5},
+		{"let x = 1; let f = fn() { let x = 2; x = 3 }; f(); x", 1},
+		{"let x = 1; let f = fn(x) { x = 9 }; f(2); x", 1},
+		{"let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c(); c()", 3},
+		{"let acc = fn() { let total = 0; fn(v) { total += v; total } }(); acc(5); acc(10)", 15},
+		{"let x = 1; if (true) { x = 2 }; x", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringLiteralObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestIndexAssignStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = [1, 2, 3]; a[1] = 5; a[1]", 5},
+		{"let a = [1, 2, 3]; a[0] += 10; a[0]", 11},
+		{"let a = []; a[0] = 1; a[1] = 2; len(a)", 2},
+		{"let a = []; let i = 0; let f = fn() { a[len(a)] = i; i += 1 }; f(); f(); f(); a[2]", 2},
+		{"let a = [1]; let b = a; b[0] = 7; a[0]", 7},
+		{"let a = [1]; let f = fn(arr) { arr[0] = 9 }; f(a); a[0]", 9},
+		{"let a = [1]; let b = push(a, 2); b[0] = 3; a[0]", 1},
+		{"let m = [[1, 2], [3, 4]]; m[1][0] = 8; m[1][0]", 8},
+		{"let h = {}; h[\"k\"] = 1; h[\"k\"]", 1},
+		{"let h = {\"k\": 1}; h[\"k\"] = 2; h[\"k\"]", 2},
+		{"let h = {\"k\": 1}; h[\"k\"] *= 6; h[\"k\"]", 6},
+		{"let h = {}; h[true] = \"yes\"; h[true]", "yes"},
+		{"let h = {}; let g = h; g[1] = 3; h[1]", 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringLiteralObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { i += 1 }; i", 10},
+		{"let i = 0; while (false) { i += 1 }; i", 0},
+		{"let i = 0; while (true) { i += 1; if (i == 5) { break } }; i", 5},
+		{"let i = 0; let odd = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue }; odd += 1 }; odd", 5},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { sum += x }; sum", 10},
+		{"let sum = 0; for (x in []) { sum += x }; sum", 0},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break }; sum += x }; sum", 3},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { continue }; sum += x }; sum", 7},
+		{"let s = \"\"; for (c in \"héllo\") { s = c + s }; s", "olléh"},
+		{"let n = 0; for (c in \"abc\") { if (c == \"b\") { break }; n += 1 }; n", 1},
+		{"let s = \"\"; for (k in {\"b\": 2, \"a\": 1, \"c\": 3}) { s += k }; s", "abc"},
+		{"let s = \"\"; for (k in {10: 0, 9: 0, -1: 0}) { s += \"${k},\" }; s", "-1,9,10,"},
+		{"let r = []; for (k in {\"1\": 0, 1: 0, \"true\": 0, true: 0, false: 0, \"a\": 0}) { r[len(r)] = k }; r[0] == false && r[1] == true && r[2] == 1 && r[3] == \"1\" && r[4] == \"a\" && r[5] == \"true\"", true},
+		{"let n = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { if (y == 2) { break }; n += 1 } }; n", 2},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10 } }; 0 }; f()", 20},
+		{"let f = fn() { while (true) { return 7 } }; f()", 7},
+		{"let fs = []; for (x in [1, 2]) { fs[len(fs)] = fn() { x } }; fs[0]() + fs[1]() * 10", 21},
+		{"let x = 5; for (x in [1, 2]) { }; x", 5},
+		{"let a = []; for (i in [1]) { }; let i = 0; while (i < 100000) { a[i] = i; i += 1 }; let sum = 0; for (x in a) { sum += x }; sum", 4999950000},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringLiteralObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 		let newAdder = fn(x) {
@@ -267,6 +427,49 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{"true < false", "unknown operator: BOOLEAN < BOOLEAN"},
+		{"true && undefined", "identifier not found: undefined"},
+		{"1 << -1", "negative shift count: -1"},
+		{"1 / 0", "division by zero: 1 / 0"},
+		{"let x = 0; 10 % x", "division by zero: 10 % 0"},
+		{"fn() { 1 / 0; 2 }()", "division by zero: 1 / 0"},
+		{"let add = fn(x, y) { x + y }; add(1)", "wrong number of arguments to `add`. got=1, want=2"},
+		{"let add = fn(x, y) { x + y }; add(1, 2, 3)", "wrong number of arguments to `add`. got=3, want=2"},
+		{"let add = fn(x, y) { x + y }; let plus = add; plus()", "wrong number of arguments to `add`. got=0, want=2"},
+		{"fn(x) { x }()", "wrong number of arguments to anonymous function. got=0, want=1"},
+		{"let f = fn() { fn(a) { a } }; f()(1, 2)", "wrong number of arguments to anonymous function. got=2, want=1"},
+		{"let f = fn(a, b = 1) { a }; f()", "wrong number of arguments to `f`. got=0, want=1 to 2"},
+		{"let f = fn(a, b = 1) { a }; f(1, 2, 3)", "wrong number of arguments to `f`. got=3, want=1 to 2"},
+		{"let f = fn(a, ...rest) { a }; f()", "wrong number of arguments to `f`. got=0, want=at least 1"},
+		{"let f = fn(a = missing) { a }; f()", "identifier not found: missing"},
+		{"let f = fn(a, b = a) { b }; f(1)", "identifier not found: a"},
+		{"y = 1", "cannot assign to undefined variable: y"},
+		{"y += 1", "cannot assign to undefined variable: y"},
+		{"let f = fn() { let z = 1 }; f(); z = 2", "cannot assign to undefined variable: z"},
+		{"len = 1", "cannot assign to undefined variable: len"},
+		{"5 = 1", "cannot assign to 5"},
+		{"let f = fn() { 1 }; f() = 3", "cannot assign to f()"},
+		{"let a = [1]; a[2] = 1", "index out of range: 2 with length 1"},
+		{"let a = [1]; a[-1] = 1", "index out of range: -1 with length 1"},
+		{"let a = [1]; a[1] += 1", "index out of range: 1 with length 1"},
+		{"let a = [1]; a[\"0\"] = 1", "array index must be INTEGER, got STRING"},
+		{"let h = {}; h[fn(x) { x }] = 1", "unusable as hash key: FUNCTION"},
+		{"let h = {}; h[\"k\"] += 1", "key not found: k"},
+		{"let s = \"abc\"; s[0] = \"x\"", "index assignment not supported: STRING"},
+		{"b[0] = 1", "identifier not found: b"},
+		{"for (x in 5) { }", "cannot iterate over INTEGER"},
+		{"while (y) { }", "identifier not found: y"},
+		{"let i = 0; while (true) { i += 1; if (i > 2) { z } }", "identifier not found: z"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1; x /= 0", "division by zero: 1 / 0"},
+		{"~1.5", "unknown operator: ~FLOAT"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{`"a" ** 2`, "type mismatch: STRING ** INTEGER"},
+		{"let n = if (false) { 1 }; n >= n", "unknown operator: NULL >= NULL"},
+		{`"a" <= 1`, "type mismatch: STRING <= INTEGER"},
+		{`"a" - "b"`, "unknown operator: STRING - STRING"},
+		{`{1.5: "a"}`, "unusable as hash key: FLOAT"},
 	}
 
 	for _, tt := range tests {
@@ -284,6 +487,276 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestCheckedArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", "integer overflow: 4611686018427387904 * 2"},
+		{"-9223372036854775808 - 1", "integer overflow: -9223372036854775808 - 1"},
+		{"let min = -9223372036854775808; min / -1", "integer overflow: -9223372036854775808 / -1"},
+		{"let min = -9223372036854775808; -min", "integer overflow: -(-9223372036854775808)"},
+		{"2 ** 63", "integer overflow: 2 ** 63"},
+		{"9223372036854775807 + 0", 9223372036854775807},
+		{"-9223372036854775808", -9223372036854775808},
+		{"-4611686018427387904 * 2", -9223372036854775808},
+		{"2 ** 62", 4611686018427387904},
+		{"(-2) ** 63", -9223372036854775808},
+		{"3 ** 39", 4052555153018976267},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWith(Config{CheckedArithmetic: true}, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	tests := []struct {
+		input        string
+		maxCallDepth int
+		expected     interface{}
+	}{
+		{"let f = fn(x) { 1 + f(x) }; f(1)", 0, "maximum recursion depth exceeded (10000 nested calls): `f` (10001 times)"},
+		{"let f = fn(n) { if (n > 0) { 1 + f(n - 1) } else { 0 } }; f(3)", 3, "maximum recursion depth exceeded (3 nested calls): `f` (4 times)"},
+		{"let f = fn(n) { if (n > 0) { 1 + f(n - 1) } else { 0 } }; f(2)", 3, 2},
+		{"let f = fn(n) { if (n > 0) { 1 + f(n - 1) } else { 0 } }; f(20000)", -1, 20000},
+		{
+			"let even = fn(n) { if (n == 0) { true } else { !odd(n - 1) == false } }; let odd = fn(n) { if (n == 0) { false } else { !even(n - 1) == false } }; fn(n) { !even(n) }(100)",
+			20,
+			"maximum recursion depth exceeded (20 nested calls): anonymous function -> `even` -> `odd` -> `even` -> `odd` -> ... 11 more ... -> `odd` -> `even` -> `odd` -> `even` -> `odd`",
+		},
+		{"let g = fn() { 1 }; let f = fn(n) { g() + if (n > 0) { f(n - 1) } else { 0 } }; f(4)", 6, 5},
+		{"let g = fn() { 1 }; let f = fn(n) { g() + if (n > 0) { f(n - 1) } else { 0 } }; f(4)", 5, "maximum recursion depth exceeded (5 nested calls): `f` (5 times) -> `g`"},
+		{"let f = fn(a = f()) { a }; f();", 3, "maximum recursion depth exceeded (3 nested calls): `f` (4 times)"},
+		{"let f = fn(a = f()) { a }; f();", 0, "maximum recursion depth exceeded (10000 nested calls): `f` (10001 times)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWith(Config{MaxCallDepth: tt.maxCallDepth}, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(1000000, 0)", 1000000},
+		{"let count = fn(n, acc) { if (n == 0) { return acc }; return count(n - 1, acc + 1) }; count(1000000, 0)", 1000000},
+		{"let count = fn(n, acc) { while (true) { if (n == 0) { return acc }; return count(n - 1, acc + 1) } }; count(100000, 0)", 100000},
+		{"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(100001)", false},
+		{"let sum = fn(arr, acc = 0) { if (len(arr) == 0) { acc } else { sum(rest(arr), acc + first(arr)) } }; sum([1, 2, 3, 4])", 10},
+		{"let f = fn(arr) { len(arr) }; f([1, 2, 3])", 3},
+		{"let make = fn(x) { fn() { x } }; let f = fn(x) { make(x * 2) }; f(21)()", 42},
+		{"let f = fn(n) { g(n) }; let g = fn(n) { n + 1 }; f(1) + f(2)", 5},
+		{"let f = fn(n) { if (n > 0) { f(n - 1) } }; f(3)", nil},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f() } }; f(1)", "wrong number of arguments to `f`. got=0, want=1"},
+		{"let f = fn() { 5() }; f()", "not a function: INTEGER"},
+		{"let f = fn() { return g() }; f()", "identifier not found: g"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testErrorObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestTailCallsDoNotCountTowardsMaxCallDepth(t *testing.T) {
+	input := "let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(100)"
+
+	testIntegerObject(t, testEvalWith(Config{MaxCallDepth: 1}, input), 0)
+}
+
+func TestEvalContext(t *testing.T) {
+	tests := []struct {
+		input string
+		cause error
+	}{
+		{"while (true) { }", context.DeadlineExceeded},
+		{"for (x in [1, 2, 3]) { while (true) { } }", context.DeadlineExceeded},
+		{"let f = fn() { f() }; f()", context.DeadlineExceeded},
+		{"1 + 2", nil},
+	}
+
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := EvalContext(ctx, program, object.NewEnvironment())
+		cancel()
+
+		errObj, ok := evaluated.(*object.Error)
+		if tt.cause == nil {
+			if ok && errObj.Cause != nil {
+				t.Errorf("evaluation of %q stopped unexpectedly: %s", tt.input, errObj.Message)
+			}
+			continue
+		}
+		if !testErrorObject(t, evaluated, "evaluation stopped: "+tt.cause.Error()) {
+			continue
+		}
+		if !errors.Is(errObj.Cause, tt.cause) {
+			t.Errorf("wrong cause. expected=%v, got=%v", tt.cause, errObj.Cause)
+		}
+	}
+}
+
+func TestEvalContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	e := New(Config{})
+	program := parser.New(lexer.New("let f = fn() { 1 }; f()")).ParseProgram()
+
+	evaluated := e.EvalContext(ctx, program, object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+	if !errors.Is(errObj.Cause, context.Canceled) {
+		t.Errorf("wrong cause. expected=%v, got=%v", context.Canceled, errObj.Cause)
+	}
+
+	// The context only applies to the evaluation it was passed to.
+	testIntegerObject(t, e.Eval(program, object.NewEnvironment()), 1)
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		config   Config
+		cause    error
+		expected string
+	}{
+		{"while (true) { }", Config{MaxSteps: 1000}, ErrStepLimitExceeded, "evaluation stopped: step limit exceeded (1000 steps)"},
+		{"let f = fn(n) { f(n + 1) }; f(0)", Config{MaxSteps: 1000}, ErrStepLimitExceeded, "evaluation stopped: step limit exceeded (1000 steps)"},
+		{"let s = \"x\"; while (true) { s = s + s }", Config{MaxAllocatedBytes: 1 << 20}, ErrMemoryLimitExceeded, "evaluation stopped: memory limit exceeded (1048576 bytes)"},
+		{"let s = \"x\"; while (true) { s = \"${s}${s}\" }", Config{MaxAllocatedBytes: 1 << 20}, ErrMemoryLimitExceeded, "evaluation stopped: memory limit exceeded (1048576 bytes)"},
+		{"let a = []; while (true) { a[len(a)] = 1 }", Config{MaxAllocatedBytes: 1 << 20}, ErrMemoryLimitExceeded, "evaluation stopped: memory limit exceeded (1048576 bytes)"},
+		{"let a = []; while (true) { a = push(a, 1) }", Config{MaxAllocatedBytes: 1 << 20}, ErrMemoryLimitExceeded, "evaluation stopped: memory limit exceeded (1048576 bytes)"},
+		{"let h = {}; let i = 0; while (true) { h[i] = i; i += 1 }", Config{MaxAllocatedBytes: 1 << 20}, ErrMemoryLimitExceeded, "evaluation stopped: memory limit exceeded (1048576 bytes)"},
+		{"while (true) { [1, 2, 3]; {1: 2} }", Config{MaxAllocatedBytes: 1 << 20}, ErrMemoryLimitExceeded, "evaluation stopped: memory limit exceeded (1048576 bytes)"},
+		{"let i = 0; while (i < 10) { i += 1 }; i", Config{MaxSteps: 1000, MaxAllocatedBytes: 1 << 20}, nil, "10"},
+		{"let s = \"\"; for (c in \"abc\") { s = s + c }; s", Config{MaxSteps: 1000, MaxAllocatedBytes: 1 << 20}, nil, "abc"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWith(tt.config, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if tt.cause == nil {
+			if ok {
+				t.Errorf("evaluation of %q failed: %s", tt.input, errObj.Message)
+			} else if evaluated.Inspect() != tt.expected {
+				t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+			}
+			continue
+		}
+		if !testErrorObject(t, evaluated, tt.expected) {
+			continue
+		}
+		if !errors.Is(errObj.Cause, tt.cause) {
+			t.Errorf("wrong cause for %q. expected=%v, got=%v", tt.input, tt.cause, errObj.Cause)
+		}
+	}
+}
+
+func TestErrorLocation(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedPos   string
+		expectedEnd   string
+		expectedStack []object.Frame
+	}{
+		{"1 + foo", "1:5", "1:8", []object.Frame{}},
+		{"let x = 5 / 0;", "1:9", "1:14", []object.Frame{}},
+		{
+			"let outer = fn() {\n  let inner = fn() { foo };\n  1 + inner()\n};\nouter()",
+			"2:22", "2:25",
+			[]object.Frame{{Function: "outer", Pos: pos(5, 1)}, {Function: "inner", Pos: pos(3, 7)}},
+		},
+		{
+			"let f = fn(g) { 1 + g() };\nf(fn() { len(1) })",
+			"2:10", "2:16",
+			[]object.Frame{{Function: "f", Pos: pos(2, 1)}, {Function: "<anonymous>", Pos: pos(1, 21)}},
+		},
+		{
+			"let f = fn(n) { if (n == 0) { g(1) } else { f(n - 1) } };\nlet g = fn() { 1 };\n1 + f(3)",
+			"1:31", "1:35",
+			[]object.Frame{{Function: "f", Pos: pos(3, 5)}},
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("pos wrong for %q. expected=%q, got=%q", tt.input, tt.expectedPos, errObj.Pos)
+		}
+		if errObj.End.String() != tt.expectedEnd {
+			t.Errorf("end wrong for %q. expected=%q, got=%q", tt.input, tt.expectedEnd, errObj.End)
+		}
+		if len(errObj.Stack) != len(tt.expectedStack) {
+			t.Errorf("stack wrong for %q. expected=%v, got=%v", tt.input, tt.expectedStack, errObj.Stack)
+			continue
+		}
+		for i, frame := range tt.expectedStack {
+			got := errObj.Stack[i]
+			if got.Function != frame.Function || got.Pos.String() != frame.Pos.String() {
+				t.Errorf("stack[%d] wrong for %q. expected=%v, got=%v", i, tt.input, frame, got)
+			}
+		}
+	}
+}
+
+func pos(line, column int) token.Position {
+	return token.Position{Line: line, Column: column}
+}
+
+func TestUncheckedArithmeticWraps(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"9223372036854775807 + 1", -9223372036854775808},
+		{"4611686018427387904 * 2", -9223372036854775808},
+		{"2 ** 64", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestReturnStatement(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -379,6 +852,53 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 > 2) == false", true},
 		{`"foo" == "foo"`, true},
 		{`"foo" == "bar"`, false},
+		{"2 <= 3", true},
+		{"3 <= 3", true},
+		{"4 <= 3", false},
+		{"3 >= 3", true},
+		{"2 >= 3", false},
+		{"1.5 <= 1", false},
+		{"1 >= 1.0", true},
+		{`"a" != "b"`, true},
+		{`"a" != "a"`, false},
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"ab" > "a"`, true},
+		{`"a" <= "a"`, true},
+		{`"B" >= "a"`, false},
+		{`"é" > "z"`, true},
+		{"1 < 2 == 2 >= 1", true},
+		{"let n = if (false) { 1 }; n == n", true},
+		{"let n = if (false) { 1 }; n != false", true},
+		{`1 == "1"`, false},
+		{`1 != "1"`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"true || false", true},
+		{"false || false", false},
+		{"1 && \"a\"", true},
+		{"let n = if (false) { 1 }; n || 0", true},
+		{"let n = if (false) { 1 }; n && true", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"let a = []; len(a) > 0 && a[0] > 1", false},
+		{"let f = fn() { undefined }; false && f()", false},
 	}
 
 	for _, tt := range tests {
@@ -421,6 +941,29 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"50 / 2 * 2 + 10", 60},
 		{"2 * (5 + 10)", 30},
 		{"3 * 3 * 3 + 10", 37},
+		{"0xff", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"010", 10},
+		{"0x7fff_ffff_ffff_ffff", 9223372036854775807},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"5 ** 0", 1},
+		{"0xf0 & 0x3c", 0x30},
+		{"0xf0 | 0x0f", 0xff},
+		{"0xff ^ 0x0f", 0xf0},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"1 << 64", 0},
+		{"~0", -1},
+		{"~5 & 0xff", 250},
+		{"1 + 2 * 3 % 4", 3},
+		{"0xdead_beef >> 16 & 0xff", 0xad},
 	}
 
 	for _, tt := range tests {
@@ -429,6 +972,52 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"3 / 2.0", 1.5},
+		{"3.0 / 2", 1.5},
+		{"10 * 0.25", 2.5},
+		{"1 - 0.25", 0.75},
+		{"50 / 200.0 * 100", 25},
+		{"1e-3 * 1000", 1},
+		{"5.5 % 2", 1.5},
+		{"2.0 ** 0.5 ** 2", 1.189207115002721},
+		{"2 ** -1", 0.5},
+		{"4 ** 0.5", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalMixedNumberComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"0.5 < 1", true},
+		{"2 > 2.5", false},
+		{"0.1 + 0.2 == 0.3", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestEvalStringLiteralExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -437,6 +1026,8 @@ func TestEvalStringLiteralExpression(t *testing.T) {
 		{`""`, ""},
 		{`"a"`, "a"},
 		{`"foobar"`, "foobar"},
+		{`"say \"hi\"\n"`, "say \"hi\"\n"},
+		{"`raw\\n`", "raw\\n"},
 	}
 
 	for _, tt := range tests {
@@ -453,6 +1044,8 @@ func TestLenBuiltinFunction(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("日本語")`, 3},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`len([])`, 0},
@@ -650,6 +1243,26 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Monkey"; "hello ${name}"`, "hello Monkey"},
+		{`let age = 7; "${age} is ${age > 5}"`, "7 is true"},
+		{`"${[1, 2 * 3]} and ${"in${"ner"}"}"`, "[1, 6] and inner"},
+		{`let f = fn(x) { x * 2 }; "f(2) = ${f(2)}."`, "f(2) = 4."},
+		{`let a = [1]; a[0] = a; "${a}"`, "[[...]]"},
+		{`let h = {}; h["self"] = h; "${h}"`, "{self: {...}}"},
+		{`"\${escaped}"`, "${escaped}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testStringLiteralObject(t, evaluated, tt.expected)
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -659,6 +1272,28 @@ func testEval(input string) object.Object {
 	return Eval(program, env)
 }
 
+func testEvalWith(config Config, input string) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+
+	return New(config).Eval(program, env)
+}
+
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Message != expected {
+		t.Errorf("object has wrong message. got=%q, expected=%q", result.Message, expected)
+		return false
+	}
+
+	return true
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
@@ -697,6 +1332,21 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, expected=%g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testStringLiteralObject(t *testing.T, obj object.Object, expected string) bool {
 	result, ok := obj.(*object.String)
 	if !ok {
@@ -2,59 +2,338 @@
 package lexer
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/freddiehaddad/monkey.interpreter/pkg/diagnostic"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/token"
 )
 
 type Lexer struct {
-	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	reader       *bufio.Reader
+	text         []byte // input from the start of the current token through the current char
+	textOffset   int    // offset of the first byte of text in the input
+	position     int    // current position in input (points to current char)
+	readPosition int    // current reading position in input (after current char)
+	ch           rune   // current char under examination
+	atEOF        bool   // the end of input has been reached
 	tokens       chan token.Token
+
+	filename    string         // file name recorded in token positions
+	line        int            // line of the current char
+	column      int            // column of the current char
+	start       token.Position // position of the first char of the current token
+	comments    bool           // emit COMMENT tokens instead of skipping comments
+	braces      []int          // unclosed braces within each embedded string expression
+	eof         token.Token    // the final token, returned once tokens is closed
+	diagnostics []diagnostic.Diagnostic
+
+	synchronous bool            // produce tokens in NextToken instead of a goroutine
+	ctx         context.Context // stops the goroutine when done
+	err         error           // why lexing stopped before the end of input
+
+	// Lines of the input held so diagnostics can show them.  Only a synchronous
+	// Lexer holds lines.
+	lineText   []byte         // the current line read so far
+	window     []string       // complete lines from the start line of the oldest recent token
+	windowLine int            // line number of window[0]
+	recent     [3]int         // start lines of the last tokens returned, oldest first
+	kept       map[int]string // complete lines retained with Retain
+	wanted     map[int]bool   // lines retained with Retain that are not complete yet
+}
+
+// Option configures optional Lexer behavior.
+type Option func(*Lexer)
+
+// WithFilename sets the file name recorded in the position of every token.
+func WithFilename(filename string) Option {
+	return func(l *Lexer) {
+		l.filename = filename
+	}
+}
+
+// WithComments makes the Lexer emit COMMENT tokens instead of discarding comments.
+// This is meant for tools, such as formatters, that need to preserve them.
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.comments = true
+	}
+}
+
+// WithSynchronous makes the Lexer produce each token when NextToken is called
+// instead of in a background goroutine.  No goroutine is started, so nothing is
+// left behind if the caller stops reading before EOF, and tokens do not pay for
+// channel synchronization.  The Lexer must then only be used by one goroutine.
+func WithSynchronous() Option {
+	return func(l *Lexer) {
+		l.synchronous = true
+	}
+}
+
+// WithContext stops the background goroutine once ctx is done.  NextToken then
+// returns EOF and Err reports why lexing stopped.  Cancel ctx when abandoning a
+// Lexer before EOF so its goroutine can exit.
+func WithContext(ctx context.Context) Option {
+	return func(l *Lexer) {
+		l.ctx = ctx
+	}
 }
 
 // New creates a new Lexer. The input variable specifies the data to be processed.
-func New(input string) *Lexer {
+// Unless the Lexer is created WithSynchronous, tokens are produced ahead of time by
+// a goroutine that exits once EOF has been read or the context is done.
+func New(input string, opts ...Option) *Lexer {
+	return NewReader(strings.NewReader(input), opts...)
+}
+
+// NewReader creates a new Lexer processing the data read from r.  The input is
+// read incrementally through a buffer as tokens are produced, so only the text of
+// the current token is held in memory.  A read error other than io.EOF ends the
+// input and is reported by Err.
+func NewReader(r io.Reader, opts ...Option) *Lexer {
 	lexer := Lexer{
-		input:  input,
-		tokens: make(chan token.Token, 10),
+		reader:     bufio.NewReader(r),
+		line:       1,
+		ctx:        context.Background(),
+		windowLine: 1,
+		kept:       make(map[int]string),
+		wanted:     make(map[int]bool),
+	}
+	for _, opt := range opts {
+		opt(&lexer)
 	}
 	lexer.readChar()
 
-	go func(lexer *Lexer) {
-		defer close(lexer.tokens)
-		for t := lexer.nextToken(); t.Type != token.EOF; t = lexer.nextToken() {
-			lexer.tokens <- t
-		}
-		lexer.tokens <- newEofToken()
-	}(&lexer)
+	if !lexer.synchronous {
+		lexer.tokens = make(chan token.Token, 10)
+		go lexer.run()
+	}
 
 	return &lexer
 }
 
+// Sends every token in the input, up to and including EOF, to the tokens channel
+// and closes it.  Stops early if the context is done.
+func (l *Lexer) run() {
+	defer close(l.tokens)
+	for {
+		t := l.nextToken()
+		if t.Type == token.EOF {
+			l.eof = t
+		}
+
+		select {
+		case l.tokens <- t:
+		case <-l.ctx.Done():
+			l.eof = token.Token{Type: token.EOF, Literal: token.EOF, Pos: t.Pos, End: t.Pos}
+			if l.err == nil {
+				l.err = l.ctx.Err()
+			}
+			return
+		}
+
+		if t.Type == token.EOF {
+			return
+		}
+	}
+}
+
+// Err returns the read error or the context's error if lexing was stopped before
+// the end of input, or nil otherwise.  The result is only meaningful once NextToken
+// has returned EOF.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
 // Advances the Lexer's input one character if we have not reached the end of input.
-// Upon reaching the end of input, `ch` is set to 0 and any additional calls to
-// readChar are undefined.
+// The input is decoded as UTF-8 and invalid bytes are read as utf8.RuneError, one
+// byte at a time.  The bytes of the character are appended to `text`.  Upon
+// reaching the end of input, `ch` is set to 0 and any additional calls to readChar
+// have no effect.
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
+	if l.atEOF {
+		return
+	}
+
+	if l.ch == '\n' {
+		l.endLine()
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+
+	size := 1
+	buf, err := l.reader.Peek(utf8.UTFMax)
+	if len(buf) == 0 {
+		if err != io.EOF && l.err == nil {
+			l.err = err
+		}
 		l.ch = 0
+		l.atEOF = true
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, size = utf8.DecodeRune(buf)
+		l.text = append(l.text, buf[:size]...)
+		if l.synchronous && l.ch != '\n' {
+			l.lineText = append(l.lineText, buf[:size]...)
+		}
+		l.reader.Discard(size)
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += size
+}
+
+// Moves the current line, which has been read completely, to the window of held
+// lines.
+func (l *Lexer) endLine() {
+	if !l.synchronous {
+		return
+	}
+
+	text := strings.TrimSuffix(string(l.lineText), "\r")
+	l.lineText = l.lineText[:0]
+
+	if l.wanted[l.line] {
+		l.kept[l.line] = text
+		delete(l.wanted, l.line)
+	}
+	l.window = append(l.window, text)
+}
+
+// Records the start line of a token returned by NextToken and discards the held
+// lines before the start line of the oldest recent token.
+func (l *Lexer) recordLine(line int) {
+	if !l.synchronous {
+		return
+	}
+
+	copy(l.recent[:], l.recent[1:])
+	l.recent[len(l.recent)-1] = line
+
+	if drop := l.recent[0] - l.windowLine; drop > 0 {
+		n := copy(l.window, l.window[drop:])
+		for i := n; i < len(l.window); i++ {
+			l.window[i] = ""
+		}
+		l.window = l.window[:n]
+		l.windowLine += drop
+	}
+}
+
+// Retain keeps line n of the input so that Line returns it for the rest of the
+// Lexer's life.  The line must still be held, which is the case for the lines of
+// the last few tokens returned by NextToken.  Only a synchronous Lexer holds
+// lines; Retain does nothing otherwise.
+func (l *Lexer) Retain(n int) {
+	if !l.synchronous {
+		return
+	}
+
+	if n == l.line {
+		l.wanted[n] = true
+	} else if text, ok := l.Line(n); ok {
+		l.kept[n] = text
+	}
+}
+
+// Line returns the text of line n of the input without the line terminator, if
+// it is held.  The current line is returned as far as it has been read.
+func (l *Lexer) Line(n int) (string, bool) {
+	if !l.synchronous {
+		return "", false
+	}
+
+	if text, ok := l.kept[n]; ok {
+		return text, true
+	}
+	if i := n - l.windowLine; i >= 0 && i < len(l.window) {
+		return l.window[i], true
+	}
+	if n == l.line {
+		return strings.TrimSuffix(string(l.lineText), "\r"), true
+	}
+	return "", false
+}
+
+// Discards the text read before the current char.  Called at the start of every
+// token so `text` only holds the current token.
+func (l *Lexer) mark() {
+	n := copy(l.text, l.text[l.position-l.textOffset:])
+	l.text = l.text[:n]
+	l.textOffset = l.position
+}
+
+// Returns the input from position, which must be within the current token, up to
+// but not including the current char.
+func (l *Lexer) slice(position int) string {
+	return string(l.text[position-l.textOffset : l.position-l.textOffset])
+}
+
+// Returns the bytes of the current char as they appear in the input.
+func (l *Lexer) raw() []byte {
+	return l.text[l.position-l.textOffset:]
+}
+
+// Returns the position of the current character.
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+// Records a diagnostic spanning from the start of the current token up to the
+// current char.
+func (l *Lexer) report(code diagnostic.Code, msg string, hints ...string) {
+	l.reportAt(l.start, code, msg, hints...)
+}
+
+// Records a diagnostic spanning from pos up to the current char.
+func (l *Lexer) reportAt(pos token.Position, code diagnostic.Code, msg string, hints ...string) {
+	d := diagnostic.Diagnostic{
+		Severity: diagnostic.ERROR,
+		Code:     code,
+		Pos:      pos,
+		End:      l.currentPosition(),
+		Message:  msg,
+		Hints:    hints,
+	}
+	l.diagnostics = append(l.diagnostics, d)
+	l.Retain(pos.Line)
+}
+
+// Diagnostics returns the problems found in the input, such as illegal characters,
+// in the order they were encountered.  Every ILLEGAL token has a corresponding
+// diagnostic.  The result is only complete once NextToken has returned EOF.
+func (l *Lexer) Diagnostics() []diagnostic.Diagnostic {
+	return l.diagnostics
 }
 
 // Returns the next character in the input without advancing.  Returns 0 when the
 // end of input is reached.
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+func (l *Lexer) peekChar() rune {
+	buf, _ := l.reader.Peek(utf8.UTFMax)
+	if len(buf) == 0 {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRune(buf)
+		return ch
 	}
 }
 
+// Checks if the current char is a byte that is not valid UTF-8.
+func (l *Lexer) invalidChar() bool {
+	return l.ch == utf8.RuneError && l.readPosition-l.position == 1
+}
+
 // Advances lexer position past all sequential whitespace characters stopping when
 // reaching a non-whitespace character or the end of file.
 func (l *Lexer) consumeWhitespace() {
@@ -63,62 +342,396 @@ func (l *Lexer) consumeWhitespace() {
 	}
 }
 
-// Checks if ch is an alpha character or the underscore returning true if ch meets
+// Checks if ch is a Unicode letter or the underscore returning true if ch meets
 // the criteria.  False otherwise.
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-// Returns the sequence of characters matching the `isLetter` criteria.
+// Returns the identifier starting at the current char.  Identifiers start with a
+// character matching the `isLetter` criteria followed by any number of letters and
+// Unicode digits.
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
+		l.readChar()
+	}
+
+	return l.slice(position)
+}
+
+// Returns the contents of a string literal up to the next " or ${ with every
+// escape sequence replaced by the character it represents.  The second return
+// value is false when the string contains an invalid escape sequence.
+func (l *Lexer) readString() (string, bool) {
+	var out strings.Builder
+	valid := true
+
+	for l.ch != '"' && l.ch != 0 && !(l.ch == '$' && l.peekChar() == '{') {
+		if l.ch == '\\' {
+			ch, ok := l.readEscape()
+			if ok {
+				out.WriteRune(ch)
+			} else {
+				valid = false
+			}
+			continue
+		}
+
+		out.Write(l.raw())
+		l.readChar()
+	}
+
+	return out.String(), valid
+}
+
+// Returns the character represented by the escape sequence starting at the current
+// char, a backslash, and advances past the sequence.  Invalid escape sequences are
+// reported and false is returned.
+//
+// The supported sequences are \n, \t, \r, \\, \", \$ and \u{...} where the braces
+// hold the hexadecimal value of a Unicode code point.
+func (l *Lexer) readEscape() (rune, bool) {
+	pos := l.currentPosition()
+	l.readChar()
+
+	var ch rune
+	switch l.ch {
+	case 'n':
+		ch = '\n'
+	case 't':
+		ch = '\t'
+	case 'r':
+		ch = '\r'
+	case '\\':
+		ch = '\\'
+	case '"':
+		ch = '"'
+	case '$':
+		ch = '$'
+	case 'u':
+		return l.readUnicodeEscape(pos)
+	case 0:
+		// The unterminated string is reported by the caller.
+		return 0, false
+	default:
+		msg := fmt.Sprintf("invalid escape sequence \\%c", l.ch)
 		l.readChar()
+		l.reportAt(pos, diagnostic.INVALID_ESCAPE, msg, `valid escape sequences are \n, \t, \r, \\, \", \$ and \u{...}`)
+		return 0, false
 	}
 
-	return l.input[position:l.position]
+	l.readChar()
+	return ch, true
 }
 
-// Returns the sequence of characters up to the next ".
-func (l *Lexer) readString() string {
+// Returns the code point of the \u{...} escape sequence starting at pos.  The
+// current char must be the u.
+func (l *Lexer) readUnicodeEscape(pos token.Position) (rune, bool) {
+	invalid := func() (rune, bool) {
+		l.reportAt(pos, diagnostic.INVALID_ESCAPE, "invalid unicode escape sequence", `use \u{...} with 1 to 6 hexadecimal digits naming a valid code point`)
+		return 0, false
+	}
+
+	l.readChar()
+	if l.ch != '{' {
+		return invalid()
+	}
+	l.readChar()
+
 	position := l.position
-	for l.ch != '"' && l.ch != 0 {
+	for isHexDigit(l.ch) {
 		l.readChar()
 	}
+	digits := l.slice(position)
 
-	return l.input[position:l.position]
+	if l.ch != '}' {
+		return invalid()
+	}
+	l.readChar()
+
+	if len(digits) == 0 || len(digits) > 6 {
+		return invalid()
+	}
+
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(value)) {
+		return invalid()
+	}
+
+	return rune(value), true
 }
 
-// Checks if ch is an integer character returning true if ch meets the criteria.
-// False otherwise.
-func isDigit(ch byte) bool {
+// Returns the token for the segment of a string literal starting at the current
+// char.  The segment ends at the closing " or at the ${ starting an embedded
+// expression, either of which is consumed.  The token type is `final` for a
+// segment ending the literal and STRING_PART for one followed by an expression.
+func (l *Lexer) readStringSegment(final token.TokenType) token.Token {
+	literal, valid := l.readString()
+
+	switch {
+	case l.ch == 0:
+		l.report(diagnostic.UNTERMINATED_STRING, "unterminated string literal", `add a closing "`)
+		return token.Token{Type: token.ILLEGAL, Literal: l.slice(l.start.Offset)}
+	case l.ch == '$':
+		l.readChar() // the $
+		l.readChar() // the {
+		l.braces = append(l.braces, 0)
+		final = token.STRING_PART
+	default:
+		l.readChar() // the "
+	}
+
+	if !valid {
+		return token.Token{Type: token.ILLEGAL, Literal: l.slice(l.start.Offset)}
+	}
+
+	return token.Token{Type: final, Literal: literal}
+}
+
+// Returns the sequence of characters up to the next backtick.  Raw strings have no
+// escape sequences and may span multiple lines.
+func (l *Lexer) readRawString() string {
+	position := l.position
+	for l.ch != '`' && l.ch != 0 {
+		l.readChar()
+	}
+
+	return l.slice(position)
+}
+
+// Returns the line comment starting at the current char.  The comment runs up to,
+// but not including, the end of the line.
+func (l *Lexer) readLineComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+
+	return l.slice(position)
+}
+
+// Returns the block comment starting at the current char including the closing
+// */.  The second return value is false when the end of input is reached before
+// the comment is closed.
+func (l *Lexer) readBlockComment() (string, bool) {
+	position := l.position
+	l.readChar() // the /
+	l.readChar() // the *
+	for !(l.ch == '*' && l.peekChar() == '/') {
+		if l.ch == 0 {
+			return l.slice(position), false
+		}
+		l.readChar()
+	}
+	l.readChar() // the *
+	l.readChar() // the /
+
+	return l.slice(position), true
+}
+
+// Checks if ch is an ASCII digit returning true if ch meets the criteria.  False
+// otherwise.
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-// Returns the sequence of characters matching the `isDigit` criteria.
-func (l *Lexer) readInteger() string {
+// Checks if ch is a hexadecimal digit returning true if ch meets the criteria.
+// False otherwise.
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// Checks if ch is a valid digit in base returning true if ch meets the criteria.
+// False otherwise.
+func isDigitOf(ch rune, base int) bool {
+	switch base {
+	case 2:
+		return ch == '0' || ch == '1'
+	case 8:
+		return '0' <= ch && ch <= '7'
+	case 16:
+		return isHexDigit(ch)
+	default:
+		return isDigit(ch)
+	}
+}
+
+// Names of the integer literal bases used in diagnostics.
+var baseNames = map[int]string{
+	2:  "binary",
+	8:  "octal",
+	10: "decimal",
+	16: "hexadecimal",
+}
+
+// Returns the base selected by the prefix character following a leading 0, or 10
+// if ch is not a base prefix.
+func prefixBase(ch rune) int {
+	switch ch {
+	case 'x', 'X':
+		return 16
+	case 'o', 'O':
+		return 8
+	case 'b', 'B':
+		return 2
+	default:
+		return 10
+	}
+}
+
+// Reads a run of digits and '_' separators starting at the current char.  Letters
+// that are hexadecimal digits are consumed for every base other than 10 so they
+// can be reported as invalid digits.  Returns the number of digits read, the first
+// digit that is not valid in base or 0 if there is none, and whether every '_'
+// separates successive digits.  `afterDigit` reports if a '_' is allowed before
+// the first digit, as it is directly after a base prefix.
+func (l *Lexer) readDigits(base int, afterDigit bool) (int, rune, bool) {
+	count := 0
+	invalid := rune(0)
+	separated := true
+
+	for isDigit(l.ch) || l.ch == '_' || base != 10 && isHexDigit(l.ch) {
+		if l.ch == '_' {
+			separated = separated && afterDigit
+			afterDigit = false
+		} else {
+			if invalid == 0 && !isDigitOf(l.ch, base) {
+				invalid = l.ch
+			}
+			count++
+			afterDigit = true
+		}
+		l.readChar()
+	}
+
+	return count, invalid, separated && (afterDigit || count == 0)
+}
+
+// Returns the number starting at the current char and its token type.  A number
+// is an INT unless it is followed by a fraction, e.g. 3.14, or an exponent, e.g.
+// 1e-9, in which case it is a FLOAT.  The fraction and exponent must contain at
+// least one digit, otherwise they are not considered part of the number.
+//
+// Integers may be written in hexadecimal, octal or binary using the prefixes 0x,
+// 0o and 0b, and digits may be separated with '_', e.g. 1_000_000.  Malformed
+// numbers are returned as ILLEGAL tokens.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
-	for isDigit(l.ch) {
+
+	if base := prefixBase(l.peekChar()); l.ch == '0' && base != 10 {
+		l.readChar() // the 0
+		l.readChar() // the prefix
+		count, invalid, separated := l.readDigits(base, true)
+		return l.checkNumber(position, token.INT, base, count, invalid, separated)
+	}
+
+	tokenType := token.TokenType(token.INT)
+	count, invalid, separated := l.readDigits(10, false)
+	if l.ch == '.' && isDigit(l.peekChar()) {
 		l.readChar()
+		_, _, ok := l.readDigits(10, false)
+		separated = separated && ok
+		tokenType = token.FLOAT
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		if next := l.peekChar(); isDigit(next) || (next == '+' || next == '-') && l.peekDigitAfterSign() {
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			_, _, ok := l.readDigits(10, false)
+			separated = separated && ok
+			tokenType = token.FLOAT
+		}
 	}
 
-	return l.input[position:l.position]
+	return l.checkNumber(position, tokenType, 10, count, invalid, separated)
+}
+
+// Reports a diagnostic if the number read since position is malformed.  Returns
+// the literal and tokenType, or ILLEGAL when the number is malformed.
+func (l *Lexer) checkNumber(position int, tokenType token.TokenType, base int, count int, invalid rune, separated bool) (string, token.TokenType) {
+	literal := l.slice(position)
+	code := diagnostic.Code(diagnostic.INVALID_INTEGER)
+	if tokenType == token.FLOAT {
+		code = diagnostic.INVALID_FLOAT
+	}
+
+	switch {
+	case count == 0:
+		l.report(code, fmt.Sprintf("%s literal has no digits", baseNames[base]))
+	case invalid != 0:
+		l.report(code, fmt.Sprintf("invalid digit %q in %s literal", invalid, baseNames[base]))
+	case !separated:
+		l.report(code, "'_' must separate successive digits", "remove the extra '_'")
+	default:
+		return literal, tokenType
+	}
+
+	return literal, token.ILLEGAL
+}
+
+// Checks if the character after the exponent sign following the current char is
+// a digit.
+func (l *Lexer) peekDigitAfterSign() bool {
+	buf, _ := l.reader.Peek(2) // the sign is always a single byte
+	return len(buf) == 2 && isDigit(rune(buf[1]))
 }
 
 // Returns the next token in the input.
 func (l *Lexer) NextToken() token.Token {
+	if l.synchronous {
+		if l.eof.Type == token.EOF {
+			return l.eof
+		}
+		t := l.nextToken()
+		if t.Type == token.EOF {
+			l.eof = t
+		}
+		return t
+	}
+
 	t, ok := <-l.tokens
 	if !ok {
-		return newEofToken()
+		return l.eof
 	}
 	return t
 }
 
-// Returns the next token in the input.
+// Returns the next token in the input with its source positions recorded.
+// Comments are skipped unless the Lexer was created WithComments.
 func (l *Lexer) nextToken() token.Token {
-	var tok token.Token
+	for {
+		l.consumeWhitespace()
+		l.mark()
 
-	l.consumeWhitespace()
+		l.start = l.currentPosition()
+		reported := len(l.diagnostics)
+
+		tok := l.readToken()
+		tok.Pos = l.start
+		if tok.Type == token.EOF {
+			tok.End = l.start
+		} else {
+			tok.End = l.currentPosition()
+		}
+
+		if tok.Type == token.ILLEGAL && len(l.diagnostics) == reported {
+			l.report(diagnostic.ILLEGAL_CHARACTER, fmt.Sprintf("illegal character %q", tok.Literal))
+		}
+
+		if tok.Type != token.COMMENT || l.comments {
+			l.recordLine(tok.Pos.Line)
+			return tok
+		}
+	}
+}
+
+// Returns the next token in the input.  The current char must be the first char
+// of the token.
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case ',':
@@ -132,8 +745,21 @@ func (l *Lexer) nextToken() token.Token {
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '{':
+		if len(l.braces) > 0 {
+			l.braces[len(l.braces)-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		if len(l.braces) > 0 {
+			top := len(l.braces) - 1
+			if l.braces[top] == 0 {
+				// The } ends an embedded expression and the string continues.
+				l.braces = l.braces[:top]
+				l.readChar()
+				return l.readStringSegment(token.STRING_END)
+			}
+			l.braces[top]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
@@ -153,9 +779,46 @@ func (l *Lexer) nextToken() token.Token {
 	case '-':
 		tok = newToken(token.MINUS, l.ch)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.POWER, Literal: literal}
+		} else {
+			tok = newToken(token.ASTERISK, l.ch)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
+	case '.':
+		if buf, _ := l.reader.Peek(2); string(buf) == ".." {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '^':
+		tok = newToken(token.CARET, l.ch)
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		switch l.peekChar() {
+		case '/':
+			tok.Literal = l.readLineComment()
+			tok.Type = token.COMMENT
+			return tok
+		case '*':
+			literal, terminated := l.readBlockComment()
+			if terminated {
+				tok = token.Token{Type: token.COMMENT, Literal: literal}
+			} else {
+				tok = token.Token{Type: token.ILLEGAL, Literal: literal}
+				l.report(diagnostic.UNTERMINATED_COMMENT, "unterminated block comment", "add a closing */")
+			}
+			return tok
+		default:
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -166,16 +829,62 @@ func (l *Lexer) nextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.LT_EQ, Literal: literal}
+		} else if l.peekChar() == '<' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.SHIFT_LEFT, Literal: literal}
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.GT_EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.SHIFT_RIGHT, Literal: literal}
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.AND, Literal: literal}
+		} else {
+			tok = newToken(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.OR, Literal: literal}
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
 	case '"':
 		l.readChar()
-		literal := l.readString()
-		if l.ch == '"' {
+		return l.readStringSegment(token.STRING)
+	case '`':
+		l.readChar()
+		literal := l.readRawString()
+		if l.ch == '`' {
 			tok = token.Token{Type: token.STRING, Literal: literal}
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = token.Token{Type: token.ILLEGAL, Literal: "`" + literal}
+			l.report(diagnostic.UNTERMINATED_STRING, "unterminated raw string literal", "add a closing `")
 		}
 	case 0:
 		tok = newEofToken()
@@ -185,14 +894,23 @@ func (l *Lexer) nextToken() token.Token {
 			tok.Type = token.LookupIdentifier(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readInteger()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
+			return tok
+		} else if l.invalidChar() {
+			tok = token.Token{Type: token.ILLEGAL, Literal: string(l.raw())}
+			l.readChar()
+			l.report(diagnostic.INVALID_UTF8, "invalid UTF-8 encoding", "Monkey source must be encoded as UTF-8")
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
+	if assign, ok := token.LookupCompoundAssignment(tok.Type); ok && l.peekChar() == '=' {
+		l.readChar()
+		tok = token.Token{Type: assign, Literal: tok.Literal + string(l.ch)}
+	}
+
 	l.readChar()
 	return tok
 }
@@ -203,6 +921,6 @@ func newEofToken() token.Token {
 }
 
 // Returns a token of tokenType and the literal value `ch`.
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
@@ -2,8 +2,14 @@
 package lexer
 
 import (
+	"context"
+	"errors"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	"github.com/freddiehaddad/monkey.interpreter/pkg/diagnostic"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/token"
 )
 
@@ -12,17 +18,14 @@ func TestNewLexer(t *testing.T) {
 		input string
 		lexer Lexer
 	}{
-		{"", Lexer{"", 0, 1, 0, nil}},
-		{" ", Lexer{" ", 0, 1, ' ', nil}},
-		{"a", Lexer{"a", 0, 1, 'a', nil}},
-		{"ab", Lexer{"ab", 0, 1, 'a', nil}},
+		{"", Lexer{position: 0, readPosition: 1, ch: 0}},
+		{" ", Lexer{position: 0, readPosition: 1, ch: ' '}},
+		{"a", Lexer{position: 0, readPosition: 1, ch: 'a'}},
+		{"ab", Lexer{position: 0, readPosition: 1, ch: 'a'}},
 	}
 
 	for i, test := range tests {
-		l := *New(test.input)
-		if test.lexer.input != l.input {
-			t.Errorf("tests[%d] failed, expected input=%s, got=%s\n", i, test.lexer.input, l.input)
-		}
+		l := *New(test.input, WithSynchronous())
 		if test.lexer.position != l.position {
 			t.Errorf("tests[%d] failed, expected position=%d, got=%d\n", i, test.lexer.position, l.position)
 		}
@@ -46,7 +49,7 @@ func TestReadChar(t *testing.T) {
 	}
 
 	for i, test := range tests {
-		l := New(test.input)
+		l := New(test.input, WithSynchronous())
 		for p, rp := 0, 1; p < len(test.input); p, rp = p+1, rp+1 {
 			if p != l.position {
 				t.Errorf("tests[%d] failed, expected l.position=%d, got l.position=%d\n", i, p, l.position)
@@ -54,7 +57,7 @@ func TestReadChar(t *testing.T) {
 			if rp != l.readPosition {
 				t.Errorf("tests[%d] failed, expected l.readPosition=%d, got l.readPosition=%d\n", i, rp, l.readPosition)
 			}
-			if test.input[p] != l.ch {
+			if rune(test.input[p]) != l.ch {
 				t.Errorf("tests[%d] failed, expected l.ch=%q, got l.ch=%q\n", i, test.input[p], l.ch)
 			}
 
@@ -207,6 +210,170 @@ func TestNextToken(t *testing.T) {
 	}
 }
 
+// Input used to compare the synchronous and goroutine lexers.
+const modesInput = `
+let fib = fn(n) {
+	// naïve recursion
+	if (n < 2) { return n; }
+	fib(n - 1) + fib(n - 2)
+};
+let s = "fib(${10}) = ${fib(10)}";
+let h = {"ratio": 0.5e1, 0xff: [1_000, 0b10]};
+/* unterminated`
+
+func TestNextTokenModes(t *testing.T) {
+	async := New(modesInput)
+	sync := New(modesInput, WithSynchronous())
+
+	for i := 0; ; i++ {
+		expected := async.NextToken()
+		tok := sync.NextToken()
+		if tok != expected {
+			t.Fatalf("tokens[%d] - wrong token. expected=%+v, got=%+v", i, expected, tok)
+		}
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	if eof := sync.NextToken(); eof.Type != token.EOF {
+		t.Errorf("expected EOF after EOF, got=%q", eof.Type)
+	}
+	if len(sync.Diagnostics()) != 1 || len(async.Diagnostics()) != 1 {
+		t.Errorf("wrong number of diagnostics. sync=%d, async=%d", len(sync.Diagnostics()), len(async.Diagnostics()))
+	}
+	if sync.Err() != nil || async.Err() != nil {
+		t.Errorf("unexpected error. sync=%v, async=%v", sync.Err(), async.Err())
+	}
+}
+
+func TestNextTokenCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := New(modesInput, WithContext(ctx))
+
+	if tok := l.NextToken(); tok.Type != token.LET {
+		t.Fatalf("wrong first token. expected=%q, got=%q", token.LET, tok.Type)
+	}
+	cancel()
+
+	// Tokens sent before the cancellation may still be delivered.
+	for i := 0; ; i++ {
+		if i > 100 {
+			t.Fatalf("lexer did not stop after the context was canceled")
+		}
+		if l.NextToken().Type == token.EOF {
+			break
+		}
+	}
+
+	if !errors.Is(l.Err(), context.Canceled) {
+		t.Errorf("wrong error. expected=%v, got=%v", context.Canceled, l.Err())
+	}
+	if _, ok := <-l.tokens; ok {
+		t.Errorf("tokens channel not closed")
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	expected := New(modesInput, WithSynchronous())
+	l := NewReader(iotest.OneByteReader(strings.NewReader(modesInput)))
+
+	for i := 0; ; i++ {
+		want := expected.NextToken()
+		tok := l.NextToken()
+		if tok != want {
+			t.Fatalf("tokens[%d] - wrong token. expected=%+v, got=%+v", i, want, tok)
+		}
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	if len(l.Diagnostics()) != len(expected.Diagnostics()) {
+		t.Errorf("wrong number of diagnostics. expected=%d, got=%d", len(expected.Diagnostics()), len(l.Diagnostics()))
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	failure := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("let x = 5"), iotest.ErrReader(failure))
+	l := NewReader(r, WithSynchronous())
+
+	expected := []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.EOF}
+	for i, tt := range expected {
+		if tok := l.NextToken(); tok.Type != tt {
+			t.Errorf("tokens[%d] - type wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+
+	if !errors.Is(l.Err(), failure) {
+		t.Errorf("wrong error. expected=%v, got=%v", failure, l.Err())
+	}
+}
+
+func TestLines(t *testing.T) {
+	input := "let a = 1;\r\nlet b = #;\n\n\nlet c = 3;\nlet d = 4;\nlet e = 5;"
+	l := NewReader(strings.NewReader(input), WithSynchronous())
+
+	// Read up to and including the `let` on line 5.
+	for tok := l.NextToken(); tok.Pos.Line < 5; tok = l.NextToken() {
+	}
+	l.Retain(5)
+
+	for l.NextToken().Type != token.EOF {
+	}
+
+	tests := []struct {
+		line     int
+		expected string
+		held     bool
+	}{
+		{1, "", false},
+		{2, "let b = #;", true}, // retained for the illegal character
+		{3, "", false},
+		{5, "let c = 3;", true},
+		{6, "", false},
+		{7, "let e = 5;", true},
+	}
+
+	for _, tt := range tests {
+		text, ok := l.Line(tt.line)
+		if ok != tt.held {
+			t.Errorf("line %d - held wrong. expected=%t, got=%t", tt.line, tt.held, ok)
+		}
+		if text != tt.expected {
+			t.Errorf("line %d - text wrong. expected=%q, got=%q", tt.line, tt.expected, text)
+		}
+	}
+
+	if _, ok := New(input).Line(1); ok {
+		t.Errorf("lines held by a Lexer that is not synchronous")
+	}
+}
+
+func benchmarkNextToken(b *testing.B, opts ...Option) {
+	var input strings.Builder
+	for i := 0; i < 100; i++ {
+		input.WriteString(modesInput[:strings.Index(modesInput, "/*")])
+	}
+	b.SetBytes(int64(input.Len()))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := New(input.String(), opts...)
+		for l.NextToken().Type != token.EOF {
+		}
+	}
+}
+
+func BenchmarkNextTokenGoroutine(b *testing.B) {
+	benchmarkNextToken(b)
+}
+
+func BenchmarkNextTokenSynchronous(b *testing.B) {
+	benchmarkNextToken(b, WithSynchronous())
+}
+
 func TestNextTokenWhitespace(t *testing.T) {
 	input := ",\n;\t==\r! !="
 
@@ -265,7 +432,7 @@ func TestNextTokenKeywords(t *testing.T) {
 
 func TestNextTokenIdentifiers(t *testing.T) {
 	input := `lets let fns fn returns return ifs if elses else
-	          trues true falses false _abc ab_c abc_`
+	          trues true falses false _abc ab_c abc_ x1 naïve π 変数 x١`
 
 	tests := []struct {
 		expectedType    token.TokenType
@@ -288,6 +455,11 @@ func TestNextTokenIdentifiers(t *testing.T) {
 		{token.IDENT, "_abc"},
 		{token.IDENT, "ab_c"},
 		{token.IDENT, "abc_"},
+		{token.IDENT, "x1"},
+		{token.IDENT, "naïve"},
+		{token.IDENT, "π"},
+		{token.IDENT, "変数"},
+		{token.IDENT, "x١"},
 		{token.EOF, "EOF"},
 	}
 
@@ -304,7 +476,7 @@ func TestNextTokenIdentifiers(t *testing.T) {
 }
 
 func TestNextTokenLogicalOperators(t *testing.T) {
-	input := `=!===!`
+	input := `=!===!<=>=<>&&||`
 	tests := []struct {
 		expectedType    token.TokenType
 		expectedLiteral string
@@ -313,6 +485,12 @@ func TestNextTokenLogicalOperators(t *testing.T) {
 		{token.NOT_EQ, "!="},
 		{token.EQ, "=="},
 		{token.BANG, "!"},
+		{token.LT_EQ, "<="},
+		{token.GT_EQ, ">="},
+		{token.LT, "<"},
+		{token.GT, ">"},
+		{token.AND, "&&"},
+		{token.OR, "||"},
 		{token.EOF, "EOF"},
 	}
 
@@ -351,8 +529,159 @@ func TestNextTokenIntegers(t *testing.T) {
 	}
 }
 
+func TestNextTokenPrefixedIntegers(t *testing.T) {
+	input := "0xFF 0Xff 0o755 0b1010 1_000_000 0x_dead_BEEF 007 1_000.5e1_0"
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0xFF"},
+		{token.INT, "0Xff"},
+		{token.INT, "0o755"},
+		{token.INT, "0b1010"},
+		{token.INT, "1_000_000"},
+		{token.INT, "0x_dead_BEEF"},
+		{token.INT, "007"},
+		{token.FLOAT, "1_000.5e1_0"},
+		{token.EOF, "EOF"},
+	}
+
+	l := New(input)
+	for i, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, test.expectedType, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q\n", i, test.expectedLiteral, tok.Literal)
+		}
+	}
+	if diagnostics := l.Diagnostics(); len(diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %v", diagnostics)
+	}
+}
+
+func TestNextTokenInvalidIntegers(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedCode    diagnostic.Code
+		expectedMessage string
+	}{
+		{"0x", diagnostic.INVALID_INTEGER, "hexadecimal literal has no digits"},
+		{"0b_", diagnostic.INVALID_INTEGER, "binary literal has no digits"},
+		{"0o78", diagnostic.INVALID_INTEGER, "invalid digit '8' in octal literal"},
+		{"0b12", diagnostic.INVALID_INTEGER, "invalid digit '2' in binary literal"},
+		{"1__000", diagnostic.INVALID_INTEGER, "'_' must separate successive digits"},
+		{"1000_", diagnostic.INVALID_INTEGER, "'_' must separate successive digits"},
+		{"0x_ff_", diagnostic.INVALID_INTEGER, "'_' must separate successive digits"},
+		{"1.5_", diagnostic.INVALID_FLOAT, "'_' must separate successive digits"},
+	}
+
+	for i, test := range tests {
+		l := New(test.input)
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, token.ILLEGAL, tok.Type)
+		}
+		if tok.Literal != test.input {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q\n", i, test.input, tok.Literal)
+		}
+		if eof := l.NextToken(); eof.Type != token.EOF {
+			t.Errorf("tests[%d] - expected EOF, got=%q\n", i, eof.Type)
+		}
+
+		diagnostics := l.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Errorf("tests[%d] - wrong number of diagnostics. expected=1, got=%d", i, len(diagnostics))
+			continue
+		}
+		d := diagnostics[0]
+		if d.Code != test.expectedCode {
+			t.Errorf("tests[%d] - code wrong. expected=%q, got=%q", i, test.expectedCode, d.Code)
+		}
+		if d.Message != test.expectedMessage {
+			t.Errorf("tests[%d] - message wrong. expected=%q, got=%q", i, test.expectedMessage, d.Message)
+		}
+		if d.Pos.Column != 1 || d.End.Column != len(test.input)+1 {
+			t.Errorf("tests[%d] - span wrong. got=%s-%s", i, d.Pos, d.End)
+		}
+	}
+}
+
+func TestNextTokenFloats(t *testing.T) {
+	input := "3.14 0.5 1e9 1e-9 2.5E+3 1.x 1e 1e+"
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "0.5"},
+		{token.FLOAT, "1e9"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "x"},
+		{token.INT, "1"},
+		{token.IDENT, "e"},
+		{token.INT, "1"},
+		{token.IDENT, "e"},
+		{token.PLUS, "+"},
+		{token.EOF, "EOF"},
+	}
+
+	l := New(input)
+	for i, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, test.expectedType, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q\n", i, test.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenCompoundAssignments(t *testing.T) {
+	input := `+= -= *= /= %= **= &= |= ^= <<= >>= <= >= == != && ||`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.PLUS_ASSIGN, "+="},
+		{token.MINUS_ASSIGN, "-="},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.SLASH_ASSIGN, "/="},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.POWER_ASSIGN, "**="},
+		{token.AMPERSAND_ASSIGN, "&="},
+		{token.PIPE_ASSIGN, "|="},
+		{token.CARET_ASSIGN, "^="},
+		{token.SHIFT_LEFT_ASSIGN, "<<="},
+		{token.SHIFT_RIGHT_ASSIGN, ">>="},
+		{token.LT_EQ, "<="},
+		{token.GT_EQ, ">="},
+		{token.EQ, "=="},
+		{token.NOT_EQ, "!="},
+		{token.AND, "&&"},
+		{token.OR, "||"},
+		{token.EOF, "EOF"},
+	}
+
+	l := New(input)
+	for i, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, test.expectedType, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q\n", i, test.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
 func TestNextTokenOperators(t *testing.T) {
-	input := `=+-*/!<>`
+	input := `=+-*/!<>%**&|^~<<>>***....`
 	tests := []struct {
 		expectedType    token.TokenType
 		expectedLiteral string
@@ -365,6 +694,18 @@ func TestNextTokenOperators(t *testing.T) {
 		{token.BANG, "!"},
 		{token.LT, "<"},
 		{token.GT, ">"},
+		{token.PERCENT, "%"},
+		{token.POWER, "**"},
+		{token.AMPERSAND, "&"},
+		{token.PIPE, "|"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.POWER, "**"},
+		{token.ASTERISK, "*"},
+		{token.ELLIPSIS, "..."},
+		{token.ILLEGAL, "."},
 		{token.EOF, "EOF"},
 	}
 
@@ -407,3 +748,297 @@ func TestNextTokenTerminals(t *testing.T) {
 		}
 	}
 }
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "let x = 5;\n\tx == \"ab\""
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{token.LET, token.Position{Filename: "test.mk", Offset: 0, Line: 1, Column: 1}, token.Position{Filename: "test.mk", Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Filename: "test.mk", Offset: 4, Line: 1, Column: 5}, token.Position{Filename: "test.mk", Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Filename: "test.mk", Offset: 6, Line: 1, Column: 7}, token.Position{Filename: "test.mk", Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Filename: "test.mk", Offset: 8, Line: 1, Column: 9}, token.Position{Filename: "test.mk", Offset: 9, Line: 1, Column: 10}},
+		{token.SEMICOLON, token.Position{Filename: "test.mk", Offset: 9, Line: 1, Column: 10}, token.Position{Filename: "test.mk", Offset: 10, Line: 1, Column: 11}},
+		{token.IDENT, token.Position{Filename: "test.mk", Offset: 12, Line: 2, Column: 2}, token.Position{Filename: "test.mk", Offset: 13, Line: 2, Column: 3}},
+		{token.EQ, token.Position{Filename: "test.mk", Offset: 14, Line: 2, Column: 4}, token.Position{Filename: "test.mk", Offset: 16, Line: 2, Column: 6}},
+		{token.STRING, token.Position{Filename: "test.mk", Offset: 17, Line: 2, Column: 7}, token.Position{Filename: "test.mk", Offset: 21, Line: 2, Column: 11}},
+		{token.EOF, token.Position{Filename: "test.mk", Offset: 21, Line: 2, Column: 11}, token.Position{Filename: "test.mk", Offset: 21, Line: 2, Column: 11}},
+	}
+
+	l := New(input, WithFilename("test.mk"))
+	for i, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, test.expectedType, tok.Type)
+		}
+		if tok.Pos != test.expectedPos {
+			t.Errorf("tests[%d] - pos wrong. expected=%+v, got=%+v\n", i, test.expectedPos, tok.Pos)
+		}
+		if tok.End != test.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%+v, got=%+v\n", i, test.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestNextTokenUnicodePositions(t *testing.T) {
+	input := "let café = \"ü\"+π;"
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     string
+		expectedEnd     string
+	}{
+		{token.LET, "let", "1:1", "1:4"},
+		{token.IDENT, "café", "1:5", "1:9"},
+		{token.ASSIGN, "=", "1:10", "1:11"},
+		{token.STRING, "ü", "1:12", "1:15"},
+		{token.PLUS, "+", "1:15", "1:16"},
+		{token.IDENT, "π", "1:16", "1:17"},
+		{token.SEMICOLON, ";", "1:17", "1:18"},
+		{token.EOF, "EOF", "1:18", "1:18"},
+	}
+
+	l := New(input)
+	for i, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, test.expectedType, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q\n", i, test.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.String() != test.expectedPos || tok.End.String() != test.expectedEnd {
+			t.Errorf("tests[%d] - span wrong. expected=%s-%s, got=%s-%s\n", i, test.expectedPos, test.expectedEnd, tok.Pos, tok.End)
+		}
+	}
+	if diagnostics := l.Diagnostics(); len(diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %v", diagnostics)
+	}
+}
+
+func TestNextTokenInvalidUTF8(t *testing.T) {
+	input := "a \xff b"
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.ILLEGAL, "\xff"},
+		{token.IDENT, "b"},
+		{token.EOF, "EOF"},
+	}
+
+	l := New(input)
+	for i, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, test.expectedType, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q\n", i, test.expectedLiteral, tok.Literal)
+		}
+	}
+
+	diagnostics := l.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. expected=1, got=%d", len(diagnostics))
+	}
+	if d := diagnostics[0]; d.Code != diagnostic.INVALID_UTF8 || d.Pos.String() != "1:3" || d.End.String() != "1:4" {
+		t.Errorf("wrong diagnostic. got=%s (end %s)", d, d.End)
+	}
+}
+
+func TestNextTokenComments(t *testing.T) {
+	input := `// a line comment
+	let x = 5; // trailing comment
+	/* a block
+	   comment */ x / 2 /**/`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.EOF, "EOF"},
+	}
+
+	l := New(input)
+	for i, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, test.expectedType, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q\n", i, test.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenKeepComments(t *testing.T) {
+	input := "x // note\n/* block */ y /* open"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.COMMENT, "// note"},
+		{token.COMMENT, "/* block */"},
+		{token.IDENT, "y"},
+		{token.ILLEGAL, "/* open"},
+		{token.EOF, "EOF"},
+	}
+
+	l := New(input, WithComments())
+	for i, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, test.expectedType, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q\n", i, test.expectedLiteral, tok.Literal)
+		}
+	}
+
+	diagnostics := l.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. expected=1, got=%d", len(diagnostics))
+	}
+	if diagnostics[0].Code != diagnostic.UNTERMINATED_COMMENT {
+		t.Errorf("diagnostic code wrong. expected=%q, got=%q", diagnostic.UNTERMINATED_COMMENT, diagnostics[0].Code)
+	}
+	if diagnostics[0].Pos.String() != "2:15" || diagnostics[0].End.String() != "2:22" {
+		t.Errorf("diagnostic span wrong. expected=2:15-2:22, got=%s-%s", diagnostics[0].Pos, diagnostics[0].End)
+	}
+}
+
+func TestNextTokenStrings(t *testing.T) {
+	input := `"a\nb" "tab\there" "\\ \"quoted\"" "\u{48}\u{e9}\u{1F600}" ` + "`raw \\n ${x}\nline`" + ` ""`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "a\nb"},
+		{token.STRING, "tab\there"},
+		{token.STRING, `\ "quoted"`},
+		{token.STRING, "Hé😀"},
+		{token.STRING, "raw \\n ${x}\nline"},
+		{token.STRING, ""},
+		{token.EOF, "EOF"},
+	}
+
+	l := New(input)
+	for i, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, test.expectedType, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q\n", i, test.expectedLiteral, tok.Literal)
+		}
+	}
+
+	if len(l.Diagnostics()) != 0 {
+		t.Errorf("unexpected diagnostics. got=%v", l.Diagnostics())
+	}
+}
+
+func TestNextTokenInvalidStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedCode    diagnostic.Code
+		expectedMessage string
+		expectedPos     string
+		expectedEnd     string
+	}{
+		{`"a\qb"`, `"a\qb"`, diagnostic.INVALID_ESCAPE, `invalid escape sequence \q`, "1:3", "1:5"},
+		{`"\u{110000}"`, `"\u{110000}"`, diagnostic.INVALID_ESCAPE, "invalid unicode escape sequence", "1:2", "1:12"},
+		{`"\u{}"`, `"\u{}"`, diagnostic.INVALID_ESCAPE, "invalid unicode escape sequence", "1:2", "1:6"},
+		{`"\u12"`, `"\u12"`, diagnostic.INVALID_ESCAPE, "invalid unicode escape sequence", "1:2", "1:4"},
+		{`"abc`, `"abc`, diagnostic.UNTERMINATED_STRING, "unterminated string literal", "1:1", "1:5"},
+		{"`abc", "`abc", diagnostic.UNTERMINATED_STRING, "unterminated raw string literal", "1:1", "1:5"},
+	}
+
+	for i, test := range tests {
+		l := New(test.input)
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, token.ILLEGAL, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q\n", i, test.expectedLiteral, tok.Literal)
+		}
+		if eof := l.NextToken(); eof.Type != token.EOF {
+			t.Errorf("tests[%d] - expected EOF, got=%q\n", i, eof.Type)
+		}
+
+		diagnostics := l.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Errorf("tests[%d] - wrong number of diagnostics. expected=1, got=%d", i, len(diagnostics))
+			continue
+		}
+		d := diagnostics[0]
+		if d.Code != test.expectedCode {
+			t.Errorf("tests[%d] - code wrong. expected=%q, got=%q", i, test.expectedCode, d.Code)
+		}
+		if d.Message != test.expectedMessage {
+			t.Errorf("tests[%d] - message wrong. expected=%q, got=%q", i, test.expectedMessage, d.Message)
+		}
+		if d.Pos.String() != test.expectedPos || d.End.String() != test.expectedEnd {
+			t.Errorf("tests[%d] - span wrong. expected=%s-%s, got=%s-%s", i, test.expectedPos, test.expectedEnd, d.Pos, d.End)
+		}
+	}
+}
+
+func TestNextTokenInterpolatedStrings(t *testing.T) {
+	input := `"hi ${name}, ${ {"a": 1}["a"] + 1 }!" "${"x${y}"}" "\${z} $5"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING_PART, "hi "},
+		{token.IDENT, "name"},
+		{token.STRING_PART, ", "},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "a"},
+		{token.RBRACKET, "]"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.STRING_END, "!"},
+		{token.STRING_PART, ""},
+		{token.STRING_PART, "x"},
+		{token.IDENT, "y"},
+		{token.STRING_END, ""},
+		{token.STRING_END, ""},
+		{token.STRING, "${z} $5"},
+		{token.EOF, "EOF"},
+	}
+
+	l := New(input)
+	for i, test := range tests {
+		tok := l.NextToken()
+		if tok.Type != test.expectedType {
+			t.Errorf("tests[%d] - type wrong. expected=%q, got=%q\n", i, test.expectedType, tok.Type)
+		}
+		if tok.Literal != test.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q\n", i, test.expectedLiteral, tok.Literal)
+		}
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"strconv"
 	"strings"
 
 	"github.com/freddiehaddad/monkey.compiler/pkg/code"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/ast"
+	"github.com/freddiehaddad/monkey.interpreter/pkg/token"
 )
 
 type ObjectType string
@@ -23,7 +25,10 @@ const (
 	NULL_OBJ              = "NULL"
 	BOOLEAN_OBJ           = "BOOLEAN"
 	INTEGER_OBJ           = "INTEGER"
+	FLOAT_OBJ             = "FLOAT"
 	RETURN_VALUE_OBJ      = "RETURN_VALUE"
+	BREAK_OBJ             = "BREAK"
+	CONTINUE_OBJ          = "CONTINUE"
 	FUNCTION_OBJ          = "FUNCTION"
 	STRING_OBJ            = "STRING"
 	BUILTIN_OBJ           = "BUILTIN"
@@ -32,13 +37,77 @@ const (
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
 )
 
+// The number of identical consecutive lines of a traceback that are printed before
+// the remaining ones are summarized.
+const TRACEBACK_REPEATS = 3
+
 // Errors
 type Error struct {
 	Message string
+	Cause   error          // the Go error behind the error, if any, e.g. context.Canceled
+	Pos     token.Position // start of the node that failed, invalid if unknown
+	End     token.Position // end of the node that failed
+	Stack   []Frame        // functions being called when the error occurred, outermost first
+}
+
+// Frame is a call of a function that was active when an error occurred.
+type Frame struct {
+	Function string         // the name of the function or <anonymous>
+	Pos      token.Position // position of the call
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+
+// Returns the message of the error.  When the position of the error is known it is
+// preceded by a traceback in the style of Python, listing for each active function
+// call, outermost first, the position in the caller that was being evaluated.
+// Runs of identical lines, such as those of deep recursion, are summarized.
+func (e *Error) Inspect() string {
+	if !e.Pos.IsValid() {
+		return "ERROR: " + e.Message
+	}
+
+	var out bytes.Buffer
+
+	out.WriteString("Traceback (most recent call last):\n")
+
+	function := "<program>"
+	previous, repeats := "", 0
+	for i := 0; i <= len(e.Stack); i++ {
+		pos := e.Pos
+		if i < len(e.Stack) {
+			pos = e.Stack[i].Pos
+		}
+
+		line := fmt.Sprintf("  at %s, in %s\n", pos, function)
+		if line == previous {
+			repeats++
+		} else {
+			writeRepeats(&out, repeats)
+			previous, repeats = line, 1
+		}
+		if repeats <= TRACEBACK_REPEATS {
+			out.WriteString(line)
+		}
+
+		if i < len(e.Stack) {
+			function = e.Stack[i].Function
+		}
+	}
+	writeRepeats(&out, repeats)
+
+	out.WriteString("ERROR: " + e.Message)
+
+	return out.String()
+}
+
+// Writes the summary of the lines of a traceback omitted since the previous line
+// was repeated more than TRACEBACK_REPEATS times.
+func writeRepeats(out *bytes.Buffer, repeats int) {
+	if repeats > TRACEBACK_REPEATS {
+		fmt.Fprintf(out, "  [previous line repeated %d more times]\n", repeats-TRACEBACK_REPEATS)
+	}
+}
 
 // Null
 type Null struct{}
@@ -76,6 +145,22 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// Floats are not hashable since equality of floating-point values is inexact.
+type Float struct {
+	Value float64
+}
+
+// Returns the shortest representation of the value that reads back exactly.  A
+// trailing ".0" is added to whole numbers to distinguish them from integers.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
 // Strings
 type String struct {
 	Value string
@@ -98,9 +183,25 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break and Continue signal a `break` or `continue` statement to the innermost
+// enclosing loop.  Like ReturnValue they are passed up through the blocks between
+// the statement and the loop.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 // Functions
 type Function struct {
+	Name       string // empty for anonymous functions
 	Parameters []*ast.Identifier
+	Defaults   map[string]ast.Expression // evaluated in Env when an argument is omitted
+	Rest       *ast.Identifier           // bound to an Array of the remaining arguments
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -109,10 +210,7 @@ func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
+	params := ast.ParameterStrings(f.Parameters, f.Defaults, f.Rest)
 
 	out.WriteString("fn")
 	out.WriteString("(")
@@ -124,18 +222,46 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
-// Arrays
+// Arrays are mutable and shared by reference: assigning to an element of an array
+// is visible through every variable referring to it.  Builtins such as push and
+// rest do not modify their argument and return a new array instead.
 type Array struct {
 	Elements []Object
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
-func (ao *Array) Inspect() string {
+func (ao *Array) Inspect() string  { return inspect(ao, map[Object]bool{}) }
+
+// Returns the Inspect string of obj.  An array or hash that contains itself is
+// printed as [...] or {...} where it recurs, so seen holds the arrays and hashes
+// currently being printed.
+func inspect(obj Object, seen map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if seen[obj] {
+			return "[...]"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+		return obj.inspect(seen)
+	case *Hash:
+		if seen[obj] {
+			return "{...}"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+		return obj.inspect(seen)
+	default:
+		return obj.Inspect()
+	}
+}
+
+func (ao *Array) inspect(seen map[Object]bool) string {
 	var out bytes.Buffer
 
 	elements := []string{}
 	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, inspect(e, seen))
 	}
 
 	out.WriteString("[")
@@ -150,17 +276,20 @@ type Hashable interface {
 	HashKey() HashKey
 }
 
+// Hashes are mutable and shared by reference like arrays.
 type Hash struct {
 	Pairs map[HashKey]HashPair
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
+func (h *Hash) Inspect() string  { return inspect(h, map[Object]bool{}) }
+
+func (h *Hash) inspect(seen map[Object]bool) string {
 	var out bytes.Buffer
 
 	pairs := []string{}
 	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), inspect(pair.Value, seen)))
 	}
 
 	out.WriteString("{")
@@ -1,7 +1,146 @@
 // Unit tests for the object package
 package object
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/freddiehaddad/monkey.interpreter/pkg/token"
+)
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{2, "2.0"},
+		{-0.5, "-0.5"},
+		{1e21, "1e+21"},
+		{1e-9, "1e-09"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("Inspect wrong. expected=%q, got=%q", tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestErrorInspect(t *testing.T) {
+	pos := func(line, column int) token.Position {
+		return token.Position{Filename: "test.mk", Line: line, Column: column}
+	}
+
+	recursion := []Frame{{Function: "main", Pos: pos(9, 1)}}
+	for i := 0; i < 6; i++ {
+		recursion = append(recursion, Frame{Function: "f", Pos: pos(2, 5)})
+	}
+
+	tests := []struct {
+		err      *Error
+		expected string
+	}{
+		{
+			&Error{Message: "no position"},
+			"ERROR: no position",
+		},
+		{
+			&Error{Message: "identifier not found: x", Pos: pos(1, 1)},
+			"Traceback (most recent call last):\n" +
+				"  at test.mk:1:1, in <program>\n" +
+				"ERROR: identifier not found: x",
+		},
+		{
+			&Error{
+				Message: "identifier not found: foo",
+				Pos:     pos(3, 9),
+				Stack:   []Frame{{Function: "outer", Pos: pos(7, 1)}, {Function: "<anonymous>", Pos: pos(5, 2)}},
+			},
+			"Traceback (most recent call last):\n" +
+				"  at test.mk:7:1, in <program>\n" +
+				"  at test.mk:5:2, in outer\n" +
+				"  at test.mk:3:9, in <anonymous>\n" +
+				"ERROR: identifier not found: foo",
+		},
+		{
+			&Error{Message: "maximum recursion depth exceeded", Pos: pos(2, 5), Stack: recursion},
+			"Traceback (most recent call last):\n" +
+				"  at test.mk:9:1, in <program>\n" +
+				"  at test.mk:2:5, in main\n" +
+				"  at test.mk:2:5, in f\n" +
+				"  at test.mk:2:5, in f\n" +
+				"  at test.mk:2:5, in f\n" +
+				"  [previous line repeated 3 more times]\n" +
+				"ERROR: maximum recursion depth exceeded",
+		},
+	}
+
+	for i, tt := range tests {
+		if tt.err.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - Inspect() wrong.\nexpected=\n%s\ngot=\n%s", i, tt.expected, tt.err.Inspect())
+		}
+	}
+}
+
+func TestContainerInspect(t *testing.T) {
+	one := &Integer{Value: 1}
+	key := &String{Value: "k"}
+
+	array := &Array{Elements: []Object{one}}
+	array.Elements = append(array.Elements, array)
+
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: hash}
+
+	nested := &Array{Elements: []Object{hash}}
+	hash2 := &Hash{Pairs: map[HashKey]HashPair{key.HashKey(): {Key: key, Value: nested}}}
+	nested.Elements = append(nested.Elements, hash2)
+
+	shared := &Array{Elements: []Object{one}}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{array, "[1, [...]]"},
+		{hash, "{k: {...}}"},
+		{nested, "[{k: {...}}, {k: [...]}]"},
+		{&Array{Elements: []Object{shared, shared}}, "[[1], [1]]"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("tests[%d] - Inspect wrong. expected=%q, got=%q", i, tt.expected, got)
+		}
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+
+	if _, ok := inner.Assign("x", &Integer{Value: 2}); !ok {
+		t.Fatalf("Assign failed for variable defined in outer scope")
+	}
+	if x, _ := outer.Get("x"); x.(*Integer).Value != 2 {
+		t.Errorf("outer binding not updated. got=%s", x.Inspect())
+	}
+
+	inner.Set("x", &Integer{Value: 3})
+	inner.Assign("x", &Integer{Value: 4})
+	if x, _ := outer.Get("x"); x.(*Integer).Value != 2 {
+		t.Errorf("shadowed outer binding updated. got=%s", x.Inspect())
+	}
+
+	if _, ok := inner.Assign("y", &Integer{Value: 1}); ok {
+		t.Errorf("Assign succeeded for undefined variable")
+	}
+	if _, ok := inner.Get("y"); ok {
+		t.Errorf("Assign defined an undefined variable")
+	}
+}
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
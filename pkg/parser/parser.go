@@ -2,10 +2,15 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/freddiehaddad/monkey.interpreter/pkg/ast"
+	"github.com/freddiehaddad/monkey.interpreter/pkg/diagnostic"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/lexer"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/token"
 )
@@ -19,7 +24,12 @@ type Parser struct {
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 
-	errors []string
+	diagnostics []diagnostic.Diagnostic
+
+	panicking bool // an error was reported and the statement is being skipped
+	depth     int  // number of unclosed braces up to and including curToken
+	block     int  // depth of the innermost enclosing block, 0 at the top level
+	loops     int  // number of loops enclosing curToken within the current function
 }
 
 type (
@@ -31,26 +41,40 @@ type (
 const (
 	_ int = iota
 	LOWEST
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
-	LESSGREATER // < or >
-	SUM         // +
-	PRODUCT     // *
-	PREFIX      // -X or !X
+	LESSGREATER // <, >, <= or >=
+	SUM         // +, -, | or ^
+	PRODUCT     // *, /, %, &, << or >>
+	PREFIX      // -X, !X or ~X
+	POWER       // **
 	CALL        // myFunction(X)
 	INDEX       // myArray[X]
 )
 
 var precendences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.OR:          LOGICAL_OR,
+	token.AND:         LOGICAL_AND,
+	token.EQ:          EQUALS,
+	token.NOT_EQ:      EQUALS,
+	token.LT:          LESSGREATER,
+	token.GT:          LESSGREATER,
+	token.LT_EQ:       LESSGREATER,
+	token.GT_EQ:       LESSGREATER,
+	token.PLUS:        SUM,
+	token.MINUS:       SUM,
+	token.PIPE:        SUM,
+	token.CARET:       SUM,
+	token.SLASH:       PRODUCT,
+	token.ASTERISK:    PRODUCT,
+	token.PERCENT:     PRODUCT,
+	token.AMPERSAND:   PRODUCT,
+	token.SHIFT_LEFT:  PRODUCT,
+	token.SHIFT_RIGHT: PRODUCT,
+	token.POWER:       POWER,
+	token.LPAREN:      CALL,
+	token.LBRACKET:    INDEX,
 }
 
 var indentCount int = -1
@@ -76,16 +100,19 @@ func untrace(fn string) {
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:           l,
+		diagnostics: []diagnostic.Diagnostic{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_PART, p.parseInterpolatedString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
@@ -93,6 +120,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -103,6 +131,17 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
@@ -113,8 +152,47 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// Diagnostics returns the problems found in the input ordered by their position.
+// The diagnostics reported by the lexer are included once ParseProgram returns.
+func (p *Parser) Diagnostics() []diagnostic.Diagnostic {
+	return p.diagnostics
+}
+
+// Errors returns the message of every diagnostic.  Use Diagnostics for the error
+// codes and source positions.
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := []string{}
+	for _, d := range p.diagnostics {
+		errors = append(errors, d.Message)
+	}
+	return errors
+}
+
+// Records an error diagnostic spanning the token tok.  Only the first error of a
+// statement is recorded since the ones that follow are usually caused by it.
+// Errors at ILLEGAL tokens are not recorded as the lexer has already reported them.
+func (p *Parser) report(tok token.Token, code diagnostic.Code, msg string, hints ...string) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+
+	// Keep the line for printing the diagnostic when the input is streamed.
+	p.l.Retain(tok.Pos.Line)
+
+	if tok.Type == token.ILLEGAL {
+		return
+	}
+
+	d := diagnostic.Diagnostic{
+		Severity: diagnostic.ERROR,
+		Code:     code,
+		Pos:      tok.Pos,
+		End:      tok.End,
+		Message:  msg,
+		Hints:    hints,
+	}
+	p.diagnostics = append(p.diagnostics, d)
 }
 
 func (p *Parser) currentPrecedence() int {
@@ -133,25 +211,37 @@ func (p *Parser) peekPrecedence() int {
 
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.report(p.peekToken, diagnostic.UNEXPECTED_TOKEN, msg)
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	// Comments are only present when the lexer is configured to keep them.
+	for p.peekTokenIs(token.COMMENT) {
+		p.peekToken = p.l.NextToken()
+	}
+
+	switch {
+	case p.curTokenIs(token.LBRACE):
+		p.depth++
+	case p.curTokenIs(token.RBRACE) && p.depth > 0:
+		p.depth--
+	}
 }
 
+// ParseProgram parses the entire input.  Parsing continues after errors so that
+// every independent problem is reported.  Statements that could not be parsed are
+// represented by an *ast.BadStatement and expressions by an *ast.BadExpression.
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
-	program.Statements = []ast.Statement{}
+	program.Statements = p.parseStatements(token.EOF)
 
-	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
-		}
-		p.nextToken()
-	}
+	p.diagnostics = append(p.diagnostics, p.l.Diagnostics()...)
+	sort.SliceStable(p.diagnostics, func(i, j int) bool {
+		return p.diagnostics[i].Pos.Offset < p.diagnostics[j].Pos.Offset
+	})
 
 	return program
 }
@@ -181,15 +271,89 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// Parses statements until reaching a token of type `end`.  After a statement with
+// an error, the parser synchronizes at the next statement boundary.
+func (p *Parser) parseStatements(end token.TokenType) []ast.Statement {
+	statements := []ast.Statement{}
+
+	for !p.curTokenIs(end) && !p.curTokenIs(token.EOF) {
+		start := p.curToken
+		stmt := p.parseStatement()
+
+		if p.panicking {
+			p.synchronize()
+			if stmt == nil {
+				stmt = &ast.BadStatement{Token: start, EndToken: p.curToken}
+			}
+			statements = append(statements, stmt)
+
+			// The broken statement ran into the end of the enclosing block.
+			if p.depth < p.block {
+				break
+			}
+		} else if stmt != nil {
+			statements = append(statements, stmt)
+		}
+
+		p.nextToken()
+	}
+
+	return statements
+}
+
+// Skips tokens until reaching a statement boundary so parsing can resume after an
+// error.  The boundaries are a `;` ending the statement, or a `let`, `return` or
+// the `}` closing the enclosing block.  Boundaries nested in braces opened by the
+// broken statement are skipped as well.  A broken statement does not take the
+// optional `;` after it, so a `}` it ran into is still the current token.
+func (p *Parser) synchronize() {
+	p.panicking = false
+
+	for !p.curTokenIs(token.EOF) {
+		if p.depth < p.block {
+			return
+		}
+
+		if p.depth == p.block {
+			if p.curTokenIs(token.SEMICOLON) {
+				return
+			}
+
+			switch p.peekToken.Type {
+			case token.LET, token.RETURN, token.EOF:
+				return
+			case token.RBRACE:
+				if p.block > 0 {
+					return
+				}
+			}
+		}
+
+		p.nextToken()
+	}
+}
+
 // Looks at the current token's type and attempts to process the next series of
-// tokens according to the grammar for statement definition.
+// tokens according to the grammar for statement definition.  Returns nil when no
+// statement could be parsed.
 func (p *Parser) parseStatement() ast.Statement {
 	// defer untrace(trace("parseStatement"))
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseLoopControlStatement(&ast.BreakStatement{Token: p.curToken})
+	case token.CONTINUE:
+		return p.parseLoopControlStatement(&ast.ContinueStatement{Token: p.curToken})
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -216,8 +380,11 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
 
-	if p.peekTokenIs(token.SEMICOLON) {
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -236,7 +403,108 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for p.peekTokenIs(token.SEMICOLON) {
+	for !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// Implementation for the `while` statement definition.
+// The expected form is:
+//
+//	while (EXPRESSION) { STATEMENTS }
+func (p *Parser) parseWhileStatement() ast.Statement {
+	// defer untrace(trace("parseWhileStatement"))
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// Implementation for the `for` statement definition.
+// The expected form is:
+//
+//	for (IDENTIFIER in EXPRESSION) { STATEMENTS }
+func (p *Parser) parseForStatement() ast.Statement {
+	// defer untrace(trace("parseForStatement"))
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// Parses the block of a loop, in which `break` and `continue` may be used.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loops++
+	defer func() { p.loops-- }()
+
+	return p.parseBlockStatement()
+}
+
+// Implementation for the `break` and `continue` statement definitions, which must
+// appear inside a loop of the current function.
+// The expected form is:
+//
+//	break;
+//	continue;
+func (p *Parser) parseLoopControlStatement(stmt ast.Statement) ast.Statement {
+	// defer untrace(trace("parseLoopControlStatement"))
+	if p.loops == 0 {
+		msg := fmt.Sprintf("%s outside of a loop", p.curToken.Literal)
+		p.report(p.curToken, diagnostic.OUTSIDE_LOOP, msg)
+	}
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -244,13 +512,47 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 }
 
 // Implementation for the 'expression` statement definition.
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	// defer untrace(trace("parseExpressionStatement"))
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
+	if token.IsAssignment(p.peekToken.Type) {
+		return p.parseAssignStatement(stmt.Expression)
+	}
 
-	if p.peekTokenIs(token.SEMICOLON) {
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// Implementation for the assignment statement definition.
+// The expected form is:
+//
+//	IDENTIFIER = EXPRESSION;
+//	EXPRESSION[EXPRESSION] = EXPRESSION;
+//
+// where = may also be a compound assignment operator such as +=.  The target has
+// already been parsed and the peek token is the assignment operator.
+func (p *Parser) parseAssignStatement(target ast.Expression) ast.Statement {
+	// defer untrace(trace("parseAssignStatement"))
+	p.nextToken()
+	stmt := &ast.AssignStatement{Token: p.curToken, Target: target, Operator: p.curToken.Literal}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		msg := fmt.Sprintf("cannot assign to %s", target)
+		p.report(p.curToken, diagnostic.INVALID_ASSIGNMENT, msg, "only variables and elements of arrays and hashes can be assigned to")
+		return stmt
+	}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -259,7 +561,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.report(p.curToken, diagnostic.MISSING_EXPRESSION, msg, fmt.Sprintf("an expression cannot start with %s", t))
 }
 
 func (p *Parser) parseExpression(precendence int) ast.Expression {
@@ -267,7 +569,7 @@ func (p *Parser) parseExpression(precendence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		return p.badExpression(p.curToken)
 	}
 	leftExp := prefix()
 
@@ -299,22 +601,104 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	// defer untrace(trace("parseIntegerLiteral"))
 	literal := &ast.IntegerLiteral{Token: p.curToken}
 
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
-	if err != nil {
+	digits, base := integerDigits(p.curToken.Literal)
+	value, err := strconv.ParseInt(digits, base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		msg := fmt.Sprintf("integer literal %s overflows int64", p.curToken.Literal)
+		p.report(p.curToken, diagnostic.INTEGER_OVERFLOW, msg, "integers must be within -9223372036854775808 and 9223372036854775807")
+		return p.badExpression(p.curToken)
+	} else if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+		p.report(p.curToken, diagnostic.INVALID_INTEGER, msg)
+		return p.badExpression(p.curToken)
 	}
 
 	literal.Value = value
 	return literal
 }
 
+// Returns the digits of the integer literal and their base.  The lexer has
+// validated the digits, leaving only the prefix and the '_' separators to remove.
+// Decimal literals are always base 10, even with a leading 0.
+func integerDigits(literal string) (string, int) {
+	digits, base := literal, 10
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			digits, base = digits[2:], 16
+		case 'o', 'O':
+			digits, base = digits[2:], 8
+		case 'b', 'B':
+			digits, base = digits[2:], 2
+		}
+	}
+	return strings.ReplaceAll(digits, "_", ""), base
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	// defer untrace(trace("parseFloatLiteral"))
+	literal := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(p.curToken.Literal, "_", ""), 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.report(p.curToken, diagnostic.INVALID_FLOAT, msg, "floating-point literals must be within ±1.8e308")
+		return p.badExpression(p.curToken)
+	}
+
+	literal.Value = value
+	return literal
+}
+
+// ILLEGAL tokens have already been reported by the lexer, so no further errors
+// are recorded for them.
+func (p *Parser) parseIllegal() ast.Expression {
+	return p.badExpression(p.curToken)
+}
+
+// Returns a placeholder for an expression starting at `start` that could not be
+// parsed.
+func (p *Parser) badExpression(start token.Token) ast.Expression {
+	return &ast.BadExpression{Token: start, EndToken: p.curToken}
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	// defer untrace(trace("parseStringLiteral"))
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// Implementation for interpolated strings.  The lexer splits the string into
+// STRING_PART tokens, each followed by an embedded expression, and a final
+// STRING_END token.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	// defer untrace(trace("parseInterpolatedString"))
+	str := &ast.InterpolatedString{Token: p.curToken}
+
+	for {
+		if p.curToken.Literal != "" {
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+		}
+
+		if p.curTokenIs(token.STRING_END) {
+			break
+		}
+
+		p.nextToken()
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.STRING_PART) && !p.peekTokenIs(token.STRING_END) {
+			msg := fmt.Sprintf("expected } to end the embedded expression, got %s instead", p.peekToken.Type)
+			p.report(p.peekToken, diagnostic.UNEXPECTED_TOKEN, msg)
+			return p.badExpression(str.Token)
+		}
+		p.nextToken()
+	}
+
+	str.EndToken = p.curToken
+
+	return str
+}
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	// defer untrace(trace("parseInfixExpression"))
 	expression := &ast.InfixExpression{
@@ -324,6 +708,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precendence := p.currentPrecedence()
+	if expression.Token.Type == token.POWER {
+		// ** is right-associative, so a ** b ** c is a ** (b ** c).
+		precendence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precendence)
 
@@ -337,6 +725,18 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 		Operator: p.curToken.Literal,
 	}
 
+	// The magnitude of the smallest integer does not fit in an int64, so it is
+	// parsed together with the minus in front of it.
+	if expression.Operator == "-" && p.peekTokenIs(token.INT) {
+		digits, base := integerDigits(p.peekToken.Literal)
+		if value, err := strconv.ParseInt("-"+digits, base, 64); err == nil && value == math.MinInt64 {
+			minus := p.curToken
+			p.nextToken()
+			tok := token.Token{Type: token.INT, Literal: "-" + p.curToken.Literal, Pos: minus.Pos, End: p.curToken.End}
+			return &ast.IntegerLiteral{Token: tok, Value: value}
+		}
+	}
+
 	p.nextToken()
 
 	expression.Right = p.parseExpression(PREFIX)
@@ -345,12 +745,13 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	// defer untrace(trace("parseGroupedExpression"))
+	start := p.curToken
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return p.badExpression(start)
 	}
 
 	return exp
@@ -361,18 +762,18 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return p.badExpression(expression.Token)
 	}
 
 	p.nextToken()
 	expression.Condition = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return p.badExpression(expression.Token)
 	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return p.badExpression(expression.Token)
 	}
 
 	expression.Consequence = p.parseBlockStatement()
@@ -383,7 +784,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	p.nextToken()
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return p.badExpression(expression.Token)
 	}
 
 	expression.Alternative = p.parseBlockStatement()
@@ -394,47 +795,80 @@ func (p *Parser) parseIfExpression() ast.Expression {
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	// defer untrace(trace("parseBlockStatement"))
 	block := &ast.BlockStatement{Token: p.curToken}
-	block.Statements = []ast.Statement{}
+
+	outer := p.block
+	p.block = p.depth
+	defer func() { p.block = outer }()
 
 	p.nextToken()
 
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			block.Statements = append(block.Statements, stmt)
-		}
-		p.nextToken()
+	block.Statements = p.parseStatements(token.RBRACE)
+	block.EndToken = p.curToken
+
+	if !p.curTokenIs(token.RBRACE) {
+		msg := fmt.Sprintf("expected %s to close the block, got %s instead", token.RBRACE, p.curToken.Type)
+		p.report(p.curToken, diagnostic.UNEXPECTED_TOKEN, msg)
 	}
 
 	return block
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// Implementation for the parameter list of a function literal.
+// The expected form is:
+//
+//	(IDENT, IDENT = EXPRESSION, ...IDENT)
+//
+// Parameters with a default value must follow those without one and the rest
+// parameter, if any, must be last.  Returns false when the list is malformed.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
 	// defer untrace(trace("parseFunctionParameters"))
-	identifiers := []*ast.Identifier{}
+	lit.Parameters = []*ast.Identifier{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
-
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if p.peekTokenIs(token.COMMA) {
+				p.report(p.curToken, diagnostic.INVALID_PARAMETER, "the rest parameter must be the last parameter")
+				return false
+			}
+			break
+		}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
-	}
+		lit.Parameters = append(lit.Parameters, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			if lit.Defaults == nil {
+				lit.Defaults = make(map[string]ast.Expression)
+			}
+			lit.Defaults[ident.Value] = p.parseExpression(LOWEST)
+		} else if len(lit.Defaults) > 0 {
+			msg := fmt.Sprintf("parameter %s needs a default value since it follows a parameter with one", ident.Value)
+			p.report(p.curToken, diagnostic.INVALID_PARAMETER, msg)
+			return false
+		}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
-	return identifiers
+	return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseFunctionLiteral() ast.Expression {
@@ -442,15 +876,22 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return p.badExpression(lit.Token)
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return p.badExpression(lit.Token)
+	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return p.badExpression(lit.Token)
 	}
 
+	// A loop enclosing the function literal cannot be left from its body.
+	loops := p.loops
+	p.loops = 0
+	defer func() { p.loops = loops }()
+
 	lit.Body = p.parseBlockStatement()
 
 	return lit
@@ -460,6 +901,10 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	// defer untrace(trace("parseArrayLiteral"))
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if array.Elements == nil {
+		return p.badExpression(array.Token)
+	}
+	array.EndToken = p.curToken
 	return array
 }
 
@@ -473,7 +918,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 
 		key := p.parseExpression(LOWEST)
 		if !p.expectPeek(token.COLON) {
-			return nil
+			return p.badExpression(hash.Token)
 		}
 
 		p.nextToken()
@@ -483,17 +928,21 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		hash.Pairs[key] = value
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
-			return nil
+			return p.badExpression(hash.Token)
 		}
 	}
 
 	if !p.expectPeek(token.RBRACE) {
-		return nil
+		return p.badExpression(hash.Token)
 	}
 
+	hash.EndToken = p.curToken
+
 	return hash
 }
 
+// Parses a comma separated list of expressions terminated by a token of type `end`.
+// Returns nil when the list is malformed.
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -522,6 +971,10 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	// defer untrace(trace("parseCallExpression"))
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	if exp.Arguments == nil {
+		return p.badExpression(exp.Token)
+	}
+	exp.EndToken = p.curToken
 	return exp
 }
 
@@ -532,9 +985,11 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp.Index = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RBRACKET) {
-		return nil
+		return p.badExpression(exp.Token)
 	}
 
+	exp.EndToken = p.curToken
+
 	return exp
 }
 
@@ -3,9 +3,11 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/freddiehaddad/monkey.interpreter/pkg/ast"
+	"github.com/freddiehaddad/monkey.interpreter/pkg/diagnostic"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/lexer"
 )
 
@@ -178,6 +180,46 @@ func TestLetStatement(t *testing.T) {
 	}
 }
 
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedTarget   string
+		expectedOperator string
+		expectedString   string
+	}{
+		{"x = 5;", "x", "=", "x = 5;"},
+		{"y += 1 * 2", "y", "+=", "y += (1 * 2);"},
+		{"z <<= n", "z", "<<=", "z <<= n;"},
+		{"total **= 2;", "total", "**=", "total **= 2;"},
+		{"a[0] = 1;", "", "=", "(a[0]) = 1;"},
+		{"h[\"k\"] += x", "", "+=", "(h[k]) += x;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.AssignStatement. got=%T", program.Statements[0])
+		}
+		if tt.expectedTarget != "" && !testIdentifier(t, stmt.Target, tt.expectedTarget) {
+			return
+		}
+		if stmt.Operator != tt.expectedOperator {
+			t.Errorf("stmt.Operator wrong. expected=%q, got=%q", tt.expectedOperator, stmt.Operator)
+		}
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.expectedString, stmt.String())
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -287,6 +329,40 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e-9;", 1e-9},
+		{"2.5E+3;", 2500},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -321,6 +397,39 @@ func TestBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestMinimumIntegerLiteral(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"-0x8000_0000_0000_0000", "-0x8000_0000_0000_0000"},
+		{"- 9223372036854775808", "-9223372036854775808"},
+		{"-9223372036854775808 + 1", "(-9223372036854775808 + 1)"},
+		{"-9223372036854775807", "(-9223372036854775807)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expectedString {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expectedString, program.String())
+		}
+	}
+
+	p := New(lexer.New("-9223372036854775808"))
+	stmt := p.ParseProgram().Statements[0].(*ast.ExpressionStatement)
+	if !testIntegerLiteral(t, stmt.Expression, -9223372036854775808) {
+		return
+	}
+	if stmt.Expression.Pos().String() != "1:1" || stmt.Expression.End().String() != "1:21" {
+		t.Errorf("literal span wrong. got=%s-%s", stmt.Expression.Pos(), stmt.Expression.End())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -376,6 +485,17 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 / 5;", 5, "/", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"true && false;", true, "&&", false},
+		{"true || false;", true, "||", false},
+		{"5 % 5;", 5, "%", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
 		{"foobar + barfoo;", "foobar", "+", "barfoo"},
@@ -425,6 +545,50 @@ func TestOperatorPrecendenceParsing(t *testing.T) {
 			"!-a",
 			"(!(-a))",
 		},
+		{
+			"a + b <= c * d == e >= f",
+			"(((a + b) <= (c * d)) == (e >= f))",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || !c && d",
+			"((a && b) || ((!c) && d))",
+		},
+		{
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		},
+		{
+			"-a ** b",
+			"(-(a ** b))",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"a ** -b",
+			"(a ** (-b))",
+		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"a | b & c ^ d",
+			"((a | (b & c)) ^ d)",
+		},
+		{
+			"a << 1 + b >> 2",
+			"((a << 1) + (b >> 2))",
+		},
+		{
+			"~a & b == c",
+			"(((~a) & b) == c)",
+		},
 		{
 			"a + b + c",
 			"((a + b) + c)",
@@ -580,6 +744,78 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x += 1; continue; break; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("body is not 3 statements. got=%d", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("Body.Statements[1] is not ast.ContinueStatement. got=%T", stmt.Body.Statements[1])
+	}
+	if _, ok := stmt.Body.Statements[2].(*ast.BreakStatement); !ok {
+		t.Errorf("Body.Statements[2] is not ast.BreakStatement. got=%T", stmt.Body.Statements[2])
+	}
+
+	expected := "while(x < y) x += 1;continue;break;"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong. expected=%q, got=%q", expected, stmt.String())
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (item in [1, 2]) { puts(item) }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Variable, "item") {
+		return
+	}
+
+	if _, ok := stmt.Iterable.(*ast.ArrayLiteral); !ok {
+		t.Errorf("stmt.Iterable is not ast.ArrayLiteral. got=%T", stmt.Iterable)
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("body is not 1 statements. got=%d", len(stmt.Body.Statements))
+	}
+
+	expected := "for (item in [1, 2]) puts(item)"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong. expected=%q, got=%q", expected, stmt.String())
+	}
+}
+
 func TestIfElseExpression(t *testing.T) {
 	input := `if (x < y) { x } else { y }`
 
@@ -674,6 +910,49 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestFunctionLiteralWithName(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedName string
+	}{
+		{"let myFunction = fn() { };", "myFunction"},
+		{"fn() { };", ""},
+		{"let f = if (true) { fn() { } };", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var value ast.Expression
+		switch stmt := program.Statements[0].(type) {
+		case *ast.LetStatement:
+			value = stmt.Value
+		case *ast.ExpressionStatement:
+			value = stmt.Expression
+		}
+
+		// A function literal nested in the value of a let statement is not named.
+		if ie, ok := value.(*ast.IfExpression); ok && len(ie.Consequence.Statements) == 1 {
+			if stmt, ok := ie.Consequence.Statements[0].(*ast.ExpressionStatement); ok {
+				value = stmt.Expression
+			}
+		}
+
+		function, ok := value.(*ast.FunctionLiteral)
+		if !ok {
+			t.Errorf("%q - no function literal found. got=%T", tt.input, value)
+			continue
+		}
+
+		if function.Name != tt.expectedName {
+			t.Errorf("%q - function literal name wrong. expected=%q, got=%q", tt.input, tt.expectedName, function.Name)
+		}
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -703,6 +982,57 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedParams   []string
+		expectedDefaults map[string]string
+		expectedRest     string
+		expectedString   string
+	}{
+		{"fn(a, b = 10) {}", []string{"a", "b"}, map[string]string{"b": "10"}, "", "fn(a, b = 10) "},
+		{"fn(a = 1, b = x * 2) {}", []string{"a", "b"}, map[string]string{"a": "1", "b": "(x * 2)"}, "", "fn(a = 1, b = (x * 2)) "},
+		{"fn(...rest) {}", []string{}, map[string]string{}, "rest", "fn(...rest) "},
+		{"fn(a, b = 10, ...rest) {}", []string{"a", "b"}, map[string]string{"b": "10"}, "rest", "fn(a, b = 10, ...rest) "},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length of parameters wrong. expected=%d, got=%d", len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+
+		if len(function.Defaults) != len(tt.expectedDefaults) {
+			t.Errorf("length of defaults wrong. expected=%d, got=%d", len(tt.expectedDefaults), len(function.Defaults))
+		}
+		for name, expected := range tt.expectedDefaults {
+			if value, ok := function.Defaults[name]; !ok || value.String() != expected {
+				t.Errorf("default of %s wrong. expected=%q, got=%v", name, expected, value)
+			}
+		}
+
+		if tt.expectedRest == "" && function.Rest != nil {
+			t.Errorf("unexpected rest parameter %s", function.Rest)
+		} else if tt.expectedRest != "" {
+			testLiteralExpression(t, function.Rest, tt.expectedRest)
+		}
+
+		if function.String() != tt.expectedString {
+			t.Errorf("function.String() wrong. expected=%q, got=%q", tt.expectedString, function.String())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5);`
 
@@ -963,3 +1293,255 @@ func TestParshingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestNodePositions(t *testing.T) {
+	input := "let add = fn(x, y) {\n  x + y;\n};\nadd(1, [2][0]);"
+
+	l := lexer.New(input, lexer.WithFilename("test.mk"))
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 2, len(program.Statements))
+	}
+
+	letStmt := program.Statements[0].(*ast.LetStatement)
+	function := letStmt.Value.(*ast.FunctionLiteral)
+	body := function.Body.Statements[0].(*ast.ExpressionStatement)
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{program, "test.mk:1:1", "test.mk:4:15"},
+		{letStmt, "test.mk:1:1", "test.mk:3:2"},
+		{letStmt.Name, "test.mk:1:5", "test.mk:1:8"},
+		{function, "test.mk:1:11", "test.mk:3:2"},
+		{function.Body, "test.mk:1:20", "test.mk:3:2"},
+		{body.Expression, "test.mk:2:3", "test.mk:2:8"},
+		{call, "test.mk:4:1", "test.mk:4:15"},
+		{call.Arguments[1], "test.mk:4:8", "test.mk:4:14"},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedStart {
+			t.Errorf("tests[%d] - start wrong. expected=%q, got=%q", i, tt.expectedStart, tt.node.Pos())
+		}
+		if tt.node.End().String() != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%q, got=%q", i, tt.expectedEnd, tt.node.End())
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedCode    diagnostic.Code
+		expectedPos     string
+		expectedEnd     string
+		expectedMessage string
+	}{
+		{"let = 5;", diagnostic.UNEXPECTED_TOKEN, "1:5", "1:6", "expected next token to be IDENT, got = instead"},
+		{"add(1, 2;", diagnostic.UNEXPECTED_TOKEN, "1:9", "1:10", "expected next token to be ), got ; instead"},
+		{"let x = ;", diagnostic.MISSING_EXPRESSION, "1:9", "1:10", "no prefix parse function for ; found"},
+		{"let x =\n  #;", diagnostic.ILLEGAL_CHARACTER, "2:3", "2:4", `illegal character "#"`},
+		{`let s = "abc`, diagnostic.UNTERMINATED_STRING, "1:9", "1:13", "unterminated string literal"},
+		{"99999999999999999999", diagnostic.INTEGER_OVERFLOW, "1:1", "1:21", "integer literal 99999999999999999999 overflows int64"},
+		{"-9223372036854775809", diagnostic.INTEGER_OVERFLOW, "1:2", "1:21", "integer literal 9223372036854775809 overflows int64"},
+		{"1 + 0x1_0000_0000_0000_0000", diagnostic.INTEGER_OVERFLOW, "1:5", "1:28", "integer literal 0x1_0000_0000_0000_0000 overflows int64"},
+		{"0b102", diagnostic.INVALID_INTEGER, "1:1", "1:6", "invalid digit '2' in binary literal"},
+		{"fn(a = 1, b) {}", diagnostic.INVALID_PARAMETER, "1:11", "1:12", "parameter b needs a default value since it follows a parameter with one"},
+		{"fn(...a, b) {}", diagnostic.INVALID_PARAMETER, "1:7", "1:8", "the rest parameter must be the last parameter"},
+		{"fn(a, ...) {}", diagnostic.UNEXPECTED_TOKEN, "1:10", "1:11", "expected next token to be IDENT, got ) instead"},
+		{"5 = 1;", diagnostic.INVALID_ASSIGNMENT, "1:3", "1:4", "cannot assign to 5"},
+		{"f() += 1;", diagnostic.INVALID_ASSIGNMENT, "1:5", "1:7", "cannot assign to f()"},
+		{"break;", diagnostic.OUTSIDE_LOOP, "1:1", "1:6", "break outside of a loop"},
+		{"if (true) { continue }", diagnostic.OUTSIDE_LOOP, "1:13", "1:21", "continue outside of a loop"},
+		{"while (true) { fn() { break } }", diagnostic.OUTSIDE_LOOP, "1:23", "1:28", "break outside of a loop"},
+		{"for (1 in x) {}", diagnostic.UNEXPECTED_TOKEN, "1:6", "1:7", "expected next token to be IDENT, got INT instead"},
+		{"for (x of y) {}", diagnostic.UNEXPECTED_TOKEN, "1:8", "1:10", "expected next token to be IN, got IDENT instead"},
+	}
+
+	for i, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) == 0 {
+			t.Errorf("tests[%d] - no diagnostics reported", i)
+			continue
+		}
+
+		d := diagnostics[0]
+		if d.Severity != diagnostic.ERROR {
+			t.Errorf("tests[%d] - severity wrong. expected=%q, got=%q", i, diagnostic.ERROR, d.Severity)
+		}
+		if d.Code != tt.expectedCode {
+			t.Errorf("tests[%d] - code wrong. expected=%q, got=%q", i, tt.expectedCode, d.Code)
+		}
+		if d.Pos.String() != tt.expectedPos {
+			t.Errorf("tests[%d] - pos wrong. expected=%q, got=%q", i, tt.expectedPos, d.Pos)
+		}
+		if d.End.String() != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%q, got=%q", i, tt.expectedEnd, d.End)
+		}
+		if d.Message != tt.expectedMessage {
+			t.Errorf("tests[%d] - message wrong. expected=%q, got=%q", i, tt.expectedMessage, d.Message)
+		}
+		if p.Errors()[0] != tt.expectedMessage {
+			t.Errorf("tests[%d] - Errors() wrong. expected=%q, got=%q", i, tt.expectedMessage, p.Errors()[0])
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements string
+	}{
+		{
+			"let = 5; let y = 10; y;",
+			[]string{"expected next token to be IDENT, got = instead"},
+			"<bad statement>|let y = 10;|y",
+		},
+		{
+			"let x = ; let y = add(1, 2; let z = 3;",
+			[]string{
+				"no prefix parse function for ; found",
+				"expected next token to be ), got ; instead",
+			},
+			"let x = <bad expression>;|let y = <bad expression>;|let z = 3;",
+		},
+		{
+			"let f = fn(x) { let = 1; x }; f(1)",
+			[]string{"expected next token to be IDENT, got = instead"},
+			"let f = fn(x) <bad statement>x;|f(1)",
+		},
+		{
+			"if (x) { let y = } let z = 1;",
+			[]string{"no prefix parse function for } found"},
+			"ifx let y = <bad expression>;|let z = 1;",
+		},
+		{
+			"let h = {\"a\" 1, \"b\": 2}; let y = 2;",
+			[]string{"expected next token to be :, got INT instead"},
+			"let h = <bad expression>;|let y = 2;",
+		},
+		{
+			"let f = fn(x { let y = 1; y }; let z = 2;",
+			[]string{"expected next token to be ), got { instead"},
+			"let f = <bad expression>;|let z = 2;",
+		},
+		{
+			"} let x = 1; fn() { x",
+			[]string{
+				"no prefix parse function for } found",
+				"expected } to close the block, got EOF instead",
+			},
+			"<bad expression>|let x = 1;|fn() x",
+		},
+		{
+			"if (x) { y + }; let z = ;",
+			[]string{
+				"no prefix parse function for } found",
+				"no prefix parse function for ; found",
+			},
+			"ifx (y + <bad expression>)|let z = <bad expression>;",
+		},
+		{
+			"let a = fn(x) { x + };\nlet b = ;\nlet c = [;",
+			[]string{
+				"no prefix parse function for } found",
+				"no prefix parse function for ; found",
+				"no prefix parse function for ; found",
+			},
+			"let a = fn(x) (x + <bad expression>);|let b = <bad expression>;|let c = <bad expression>;",
+		},
+	}
+
+	for i, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("tests[%d] - wrong number of errors. expected=%d, got=%d (%q)", i, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+		for j, msg := range tt.expectedErrors {
+			if errors[j] != msg {
+				t.Errorf("tests[%d] - error[%d] wrong. expected=%q, got=%q", i, j, msg, errors[j])
+			}
+		}
+
+		statements := []string{}
+		for _, stmt := range program.Statements {
+			statements = append(statements, stmt.String())
+		}
+		actual := strings.Join(statements, "|")
+		if actual != tt.expectedStatements {
+			t.Errorf("tests[%d] - statements wrong. expected=%q, got=%q", i, tt.expectedStatements, actual)
+		}
+	}
+}
+
+func TestParsingWithComments(t *testing.T) {
+	input := `
+	// adds two numbers
+	let add = fn(x, y) { /* the sum */ x + y; };
+	add(1, 2); // call it`
+
+	for _, l := range []*lexer.Lexer{lexer.New(input), lexer.New(input, lexer.WithComments())} {
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		expected := "let add = fn(x, y) (x + y);add(1, 2)"
+		if program.String() != expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+		}
+	}
+}
+
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `"hello ${name}, you are ${age + 1}"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(str.Parts) != 4 {
+		t.Fatalf("str.Parts has wrong length. expected=%d, got=%d", 4, len(str.Parts))
+	}
+
+	if literal, ok := str.Parts[0].(*ast.StringLiteral); !ok || literal.Value != "hello " {
+		t.Errorf("str.Parts[0] wrong. expected=%q, got=%s", "hello ", str.Parts[0])
+	}
+	testIdentifier(t, str.Parts[1], "name")
+	if literal, ok := str.Parts[2].(*ast.StringLiteral); !ok || literal.Value != ", you are " {
+		t.Errorf("str.Parts[2] wrong. expected=%q, got=%s", ", you are ", str.Parts[2])
+	}
+	testInfixExpression(t, str.Parts[3], "age", "+", 1)
+
+	expected := "hello ${name}, you are ${(age + 1)}"
+	if str.String() != expected {
+		t.Errorf("str.String() wrong. expected=%q, got=%q", expected, str.String())
+	}
+
+	if str.End().Offset != len(input) {
+		t.Errorf("str.End() wrong. expected offset=%d, got=%d", len(input), str.End().Offset)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/freddiehaddad/monkey.interpreter/pkg/diagnostic"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/evaluator"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/lexer"
 	"github.com/freddiehaddad/monkey.interpreter/pkg/object"
@@ -14,6 +15,8 @@ import (
 
 const PROMPT = ">> "
 
+const PARSER_ERRORS = "Woops! Parser errors detected...\n"
+
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
@@ -30,8 +33,8 @@ func Start(in io.Reader, out io.Writer) {
 		p := parser.New(l)
 
 		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if len(p.Diagnostics()) != 0 {
+			printParserErrors(out, line, p.Diagnostics())
 			continue
 		}
 
@@ -43,10 +46,48 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, "Woops! Parser errors detected...\n")
-	io.WriteString(out, "  Errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// Run evaluates the program read from in, printing any errors to out.  The
+// program is lexed as it is read, so it does not need to fit in memory as a single
+// string.  The name is used in diagnostics to identify the program.  Returns false
+// if the program could not be read, parsed or evaluated.
+func Run(name string, in io.Reader, out io.Writer) bool {
+	l := lexer.NewReader(in, lexer.WithFilename(name), lexer.WithSynchronous())
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if err := l.Err(); err != nil {
+		fmt.Fprintf(out, "Failed to read %s: %s\n", name, err)
+		return false
+	}
+	if len(p.Diagnostics()) != 0 {
+		printStreamedParserErrors(out, l, p.Diagnostics())
+		return false
+	}
+
+	evaluated := evaluator.Eval(program, object.NewEnvironment())
+	if err, ok := evaluated.(*object.Error); ok {
+		io.WriteString(out, err.Inspect())
+		io.WriteString(out, "\n")
+		return false
+	}
+
+	return true
+}
+
+func printParserErrors(out io.Writer, source string, diagnostics []diagnostic.Diagnostic) {
+	io.WriteString(out, PARSER_ERRORS)
+	diagnostic.Fprints(out, source, diagnostics)
+}
+
+// Prints the diagnostics of a program that is not kept in memory.  The source
+// lines they point at are the ones retained by the synchronous lexer l.
+func printStreamedParserErrors(out io.Writer, l *lexer.Lexer, diagnostics []diagnostic.Diagnostic) {
+	io.WriteString(out, PARSER_ERRORS)
+	for _, d := range diagnostics {
+		if line, ok := l.Line(d.Pos.Line); ok {
+			diagnostic.FprintLine(out, line, d)
+		} else {
+			diagnostic.Fprint(out, "", d)
+		}
 	}
 }
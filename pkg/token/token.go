@@ -1,11 +1,45 @@
 // Tokens for the Monkey language
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // position of the first character of the token
+	End     Position // position immediately after the last character of the token
+}
+
+// Position describes a location in the source.  Line and Column start at 1 while
+// Offset is the zero based byte offset into the input.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// Reports whether the position has been set.  The zero value is not valid.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// Returns the position in the form `file:line:column`.  The file name is omitted
+// when empty and "-" is returned for invalid positions without a file name.
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
 }
 
 var keywords = map[string]TokenType{
@@ -16,6 +50,47 @@ var keywords = map[string]TokenType{
 	"return": RETURN,
 	"true":   TRUE,
 	"false":  FALSE,
+
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+}
+
+// Compound assignment operators keyed by the binary operator they apply.
+var compoundAssignments = map[TokenType]TokenType{
+	PLUS:        PLUS_ASSIGN,
+	MINUS:       MINUS_ASSIGN,
+	ASTERISK:    ASTERISK_ASSIGN,
+	SLASH:       SLASH_ASSIGN,
+	PERCENT:     PERCENT_ASSIGN,
+	POWER:       POWER_ASSIGN,
+	AMPERSAND:   AMPERSAND_ASSIGN,
+	PIPE:        PIPE_ASSIGN,
+	CARET:       CARET_ASSIGN,
+	SHIFT_LEFT:  SHIFT_LEFT_ASSIGN,
+	SHIFT_RIGHT: SHIFT_RIGHT_ASSIGN,
+}
+
+// Returns the compound assignment operator for the binary operator op, e.g. +=
+// for +.  The second return value is false if op has no compound assignment form.
+func LookupCompoundAssignment(op TokenType) (TokenType, bool) {
+	tok, ok := compoundAssignments[op]
+	return tok, ok
+}
+
+// Checks if t is = or a compound assignment operator.
+func IsAssignment(t TokenType) bool {
+	if t == ASSIGN {
+		return true
+	}
+	for _, assign := range compoundAssignments {
+		if t == assign {
+			return true
+		}
+	}
+	return false
 }
 
 func LookupIdentifier(identifier string) TokenType {
@@ -33,10 +108,19 @@ const (
 
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
+	// Interpolated strings, e.g. "a ${b} c", are split into the STRING_PART "a ",
+	// the tokens of the embedded expression b and the STRING_END " c".
+	STRING_PART = "STRING_PART"
+	STRING_END  = "STRING_END"
+
+	COMMENT = "COMMENT"
+
 	// Delimiters
 	COMMA     = ","
+	ELLIPSIS  = "..."
 	SEMICOLON = ";"
 	COLON     = ":"
 
@@ -57,10 +141,37 @@ const (
 	BANG     = "!"
 	LT       = "<"
 	GT       = ">"
+	PERCENT  = "%"
+	POWER    = "**"
+
+	// Bitwise Operators
+	AMPERSAND   = "&"
+	PIPE        = "|"
+	CARET       = "^"
+	TILDE       = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	// Compound Assignment Operators
+	PLUS_ASSIGN        = "+="
+	MINUS_ASSIGN       = "-="
+	ASTERISK_ASSIGN    = "*="
+	SLASH_ASSIGN       = "/="
+	PERCENT_ASSIGN     = "%="
+	POWER_ASSIGN       = "**="
+	AMPERSAND_ASSIGN   = "&="
+	PIPE_ASSIGN        = "|="
+	CARET_ASSIGN       = "^="
+	SHIFT_LEFT_ASSIGN  = "<<="
+	SHIFT_RIGHT_ASSIGN = ">>="
 
 	// Logical Operators
 	EQ     = "=="
 	NOT_EQ = "!="
+	LT_EQ  = "<="
+	GT_EQ  = ">="
+	AND    = "&&"
+	OR     = "||"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
@@ -70,4 +181,9 @@ const (
 	RETURN   = "RETURN"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )